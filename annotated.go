@@ -20,6 +20,17 @@
 
 package fx
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"fx-master/internal/fxreflect"
+	"go.uber.org/dig"
+)
+
 // Annotated annotates a constructor provided to Fx with additional options.
 //
 // For example,
@@ -53,16 +64,18 @@ type Annotated struct {
 	// by the constructor. For more information on named values, see the documentation
 	// for the fx.Out type.
 	//
-	// A name option may not be provided if a group option is provided.
-	// 可选 当提供group的内容 则name可以不提供
+	// A name option may be provided along with a group option, in which case
+	// the same values are both named and in the value groups.
+	// 可选 可与Group同时提供
 	// 若是指定的话 用于构造函数返回的非error值的name(更多关于Name选项 可参见文档中fx.Out类型)
 	Name string
 
 	// If specified, this will be used as the group name for all non-error values returned
 	// by the constructor. For more information on value groups, see the package documentation.
 	//
-	// A group option may not be provided if a name option is provided.
-	// 可选 当提供Name的内容 则Group可不提供
+	// Several value groups may be given, separated by commas, as in
+	// "routes,admin".
+	// 可选 多个group之间以逗号分隔
 	// 若是指定的话 可用于通过构造函数返回的非error值的group(更多关于Group选项 见包文档doc.go)
 	Group string
 
@@ -70,3 +83,405 @@ type Annotated struct {
 	// 提供给fx.Annotated的构造函数
 	Target interface{}
 }
+
+// annotate converts the Annotated to the equivalent fx.Annotate call, which
+// tags each non-error result with the name and groups.
+func (a Annotated) annotate() annotated {
+	var tags []string
+	if a.Name != "" {
+		tags = append(tags, fmt.Sprintf("name:%q", a.Name))
+	}
+	if a.Group != "" {
+		tags = append(tags, fmt.Sprintf("group:%q", a.Group))
+	}
+	tag := strings.Join(tags, " ")
+
+	ann := annotated{Target: a.Target}
+	if ft := reflect.TypeOf(a.Target); ft != nil && ft.Kind() == reflect.Func {
+		for i := 0; i < ft.NumOut(); i++ {
+			if ft.Out(i) != _typeOfError {
+				ann.ResultTags = append(ann.ResultTags, tag)
+			}
+		}
+	}
+	return ann
+}
+
+// Annotation changes how fx.Annotate presents a function to the container.
+// See ParamTags, ResultTags and As.
+type Annotation interface {
+	apply(*annotated) error
+}
+
+// ParamTags is an Annotation that tags the parameters of an annotated
+// function, in order, as if they were fields of an fx.In struct. Tags may
+// use the "name", "group" and "optional" keys. For example,
+//
+//   fx.Annotate(NewReadOnlyConnection, fx.ParamTags(`name:"ro"`))
+//
+// makes NewReadOnlyConnection's first parameter the value named "ro". Empty
+// tags leave the parameter untouched. If the function is variadic and its
+// last parameter has no tag, the parameter is optional.
+func ParamTags(tags ...string) Annotation {
+	return paramTagsAnnotation{tags: tags}
+}
+
+type paramTagsAnnotation struct {
+	tags []string
+}
+
+func (pt paramTagsAnnotation) apply(ann *annotated) error {
+	if ann.ParamTags != nil {
+		return errors.New("cannot apply more than one ParamTags")
+	}
+	if err := verifyTags(pt.tags); err != nil {
+		return err
+	}
+	ann.ParamTags = pt.tags
+	return nil
+}
+
+// ResultTags is an Annotation that tags the non-error results of an
+// annotated function, in order, as if they were fields of an fx.Out struct.
+// Tags may use the "name", "group" and "priority" keys. For example,
+//
+//   fx.Annotate(NewReadOnlyConnection, fx.ResultTags(`name:"ro"`))
+//
+// provides the *Connection returned by NewReadOnlyConnection under the name
+// "ro". Empty tags leave the result untouched.
+func ResultTags(tags ...string) Annotation {
+	return resultTagsAnnotation{tags: tags}
+}
+
+type resultTagsAnnotation struct {
+	tags []string
+}
+
+func (rt resultTagsAnnotation) apply(ann *annotated) error {
+	if ann.ResultTags != nil {
+		return errors.New("cannot apply more than one ResultTags")
+	}
+	if err := verifyTags(rt.tags); err != nil {
+		return err
+	}
+	ann.ResultTags = rt.tags
+	return nil
+}
+
+// As is an Annotation that provides the non-error results of an annotated
+// function, in order, as the given interfaces instead of their own types.
+// Each argument must be a pointer to an interface. For example,
+//
+//   fx.Annotate(NewBufferedWriter, fx.As(new(io.Writer)))
+//
+// provides the *bufio.Writer returned by NewBufferedWriter as an io.Writer.
+// The concrete type is then no longer available to other functions. Results
+// past the last interface keep their own types.
+func As(interfaces ...interface{}) Annotation {
+	return asAnnotation{targets: interfaces}
+}
+
+type asAnnotation struct {
+	targets []interface{}
+}
+
+func (at asAnnotation) apply(ann *annotated) error {
+	if ann.As != nil {
+		return errors.New("cannot apply more than one As")
+	}
+	ann.As = make([]reflect.Type, len(at.targets))
+	for i, target := range at.targets {
+		t := reflect.TypeOf(target)
+		if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+			return fmt.Errorf("fx.As received %v, expected a pointer to an interface like new(io.Writer)", t)
+		}
+		ann.As[i] = t.Elem()
+	}
+	return nil
+}
+
+// _tagRe matches struct tags made of key:"value" pairs.
+var _tagRe = regexp.MustCompile(`^(\s*\w+:"(\\.|[^"\\])*")*\s*$`)
+
+func verifyTags(tags []string) error {
+	for _, tag := range tags {
+		if !_tagRe.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q, expected something like `name:\"foo\"`", tag)
+		}
+	}
+	return nil
+}
+
+// Annotate lets plain functions take named values and value groups, and
+// provide their results under names, in value groups or as interfaces,
+// without writing fx.In and fx.Out structs. For example,
+//
+//   func NewReadOnlyConnection(cfg *Config) (*Connection, error)
+//
+//   fx.Provide(fx.Annotate(
+//     NewReadOnlyConnection,
+//     fx.ParamTags(`name:"ro"`),
+//     fx.ResultTags(`name:"ro"`),
+//   ))
+//
+// Is equivalent to,
+//
+//   type params struct {
+//     fx.In
+//
+//     Config *Config `name:"ro"`
+//   }
+//
+//   type result struct {
+//     fx.Out
+//
+//     Connection *Connection `name:"ro"`
+//   }
+//
+//   fx.Provide(func(p params) (result, error) {
+//     conn, err := NewReadOnlyConnection(p.Config)
+//     return result{Connection: conn}, err
+//   })
+//
+// The annotated function may be passed to fx.Provide, fx.Invoke or
+// fx.Decorate. Parameters that are already fx.In structs and results that
+// are already fx.Out structs can't be annotated.
+//
+// Annotate为普通函数的参数及返回值添加tag，或将返回值以接口类型提供，无需手写fx.In/fx.Out结构
+func Annotate(t interface{}, anns ...Annotation) interface{} {
+	result := annotated{Target: t}
+	for _, ann := range anns {
+		if err := ann.apply(&result); err != nil {
+			result.err = err
+			break
+		}
+	}
+	return result
+}
+
+// annotated is a function passed to Annotate, along with its annotations.
+type annotated struct {
+	Target     interface{}
+	ParamTags  []string
+	ResultTags []string
+	As         []reflect.Type
+
+	err error // first error applying the annotations
+}
+
+func (ann annotated) String() string {
+	var parts []string
+	if len(ann.ParamTags) > 0 {
+		parts = append(parts, fmt.Sprintf("fx.ParamTags(%q)", ann.ParamTags))
+	}
+	if len(ann.ResultTags) > 0 {
+		parts = append(parts, fmt.Sprintf("fx.ResultTags(%q)", ann.ResultTags))
+	}
+	if len(ann.As) > 0 {
+		parts = append(parts, fmt.Sprintf("fx.As(%v)", ann.As))
+	}
+	return fmt.Sprintf("fx.Annotate(%v)", strings.Join(append([]string{fxreflect.FuncName(ann.Target)}, parts...), ", "))
+}
+
+// build returns a function that wraps the annotated function, taking an
+// fx.In struct and returning an fx.Out struct as needed.
+func (ann annotated) build() (interface{}, error) {
+	if ann.err != nil {
+		return nil, ann.wrapError(ann.err)
+	}
+	ft := reflect.TypeOf(ann.Target)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("fx.Annotate must be passed a function, got %v", ann.Target)
+	}
+
+	paramTypes, mapParams, err := ann.params(ft)
+	if err != nil {
+		return nil, ann.wrapError(err)
+	}
+	resultTypes, mapResults, err := ann.results(ft)
+	if err != nil {
+		return nil, ann.wrapError(err)
+	}
+
+	call := reflect.ValueOf(ann.Target).Call
+	if ft.IsVariadic() {
+		call = reflect.ValueOf(ann.Target).CallSlice
+	}
+	newFT := reflect.FuncOf(paramTypes, resultTypes, false)
+	return reflect.MakeFunc(newFT, func(args []reflect.Value) []reflect.Value {
+		return mapResults(call(mapParams(args)))
+	}).Interface(), nil
+}
+
+func (ann annotated) wrapError(err error) error {
+	return fmt.Errorf("fx.Annotate failed for %v: %v", fxreflect.FuncName(ann.Target), err)
+}
+
+// params returns the parameter types of the wrapper function, and a function
+// that turns the wrapper's arguments into the annotated function's.
+func (ann annotated) params(ft reflect.Type) ([]reflect.Type, func([]reflect.Value) []reflect.Value, error) {
+	types := make([]reflect.Type, ft.NumIn())
+	for i := range types {
+		types[i] = ft.In(i)
+	}
+	if len(ann.ParamTags) == 0 && !ft.IsVariadic() {
+		return types, func(args []reflect.Value) []reflect.Value { return args }, nil
+	}
+	if len(ann.ParamTags) > len(types) {
+		return nil, nil, fmt.Errorf("got %d parameter tags for %d parameters", len(ann.ParamTags), len(types))
+	}
+
+	fields := []reflect.StructField{{
+		Name:      "In",
+		Type:      reflect.TypeOf(In{}),
+		Anonymous: true,
+	}}
+	for i, t := range types {
+		var tag string
+		if i < len(ann.ParamTags) {
+			tag = ann.ParamTags[i]
+		}
+		if tag != "" && dig.IsIn(t) {
+			return nil, nil, fmt.Errorf("parameter %d is an fx.In struct and can't be tagged", i)
+		}
+		if tag == "" && ft.IsVariadic() && i == len(types)-1 {
+			tag = `optional:"true"`
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: t,
+			Tag:  reflect.StructTag(tag),
+		})
+	}
+
+	return []reflect.Type{reflect.StructOf(fields)}, func(args []reflect.Value) []reflect.Value {
+		params := args[0]
+		mapped := make([]reflect.Value, len(types))
+		for i := range mapped {
+			mapped[i] = params.Field(i + 1)
+		}
+		return mapped
+	}, nil
+}
+
+// results returns the result types of the wrapper function, and a function
+// that turns the annotated function's results into the wrapper's.
+func (ann annotated) results(ft reflect.Type) ([]reflect.Type, func([]reflect.Value) []reflect.Value, error) {
+	if len(ann.ResultTags) == 0 && len(ann.As) == 0 {
+		types := make([]reflect.Type, ft.NumOut())
+		for i := range types {
+			types[i] = ft.Out(i)
+		}
+		return types, func(results []reflect.Value) []reflect.Value { return results }, nil
+	}
+
+	var types []reflect.Type
+	hasErr := false
+	for i := 0; i < ft.NumOut(); i++ {
+		t := ft.Out(i)
+		if t != _typeOfError {
+			types = append(types, t)
+			continue
+		}
+		if i != ft.NumOut()-1 {
+			return nil, nil, errors.New("only the last result may be an error")
+		}
+		hasErr = true
+	}
+
+	if len(ann.ResultTags) > len(types) {
+		return nil, nil, fmt.Errorf("got %d result tags for %d results", len(ann.ResultTags), len(types))
+	}
+	if len(ann.As) > len(types) {
+		return nil, nil, fmt.Errorf("got %d interfaces for %d results", len(ann.As), len(types))
+	}
+
+	fields := []reflect.StructField{{
+		Name:      "Out",
+		Type:      reflect.TypeOf(Out{}),
+		Anonymous: true,
+	}}
+	for i, t := range types {
+		if dig.IsOut(t) {
+			return nil, nil, fmt.Errorf("result %d is an fx.Out struct and can't be annotated", i)
+		}
+		var tag string
+		if i < len(ann.ResultTags) {
+			tag = ann.ResultTags[i]
+		}
+		if i < len(ann.As) {
+			if !t.Implements(ann.As[i]) {
+				return nil, nil, fmt.Errorf("result %d of type %v does not implement %v", i, t, ann.As[i])
+			}
+			t = ann.As[i]
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: t,
+			Tag:  reflect.StructTag(tag),
+		})
+	}
+
+	outType := reflect.StructOf(fields)
+	newTypes := []reflect.Type{outType}
+	if hasErr {
+		newTypes = append(newTypes, _typeOfError)
+	}
+	return newTypes, func(results []reflect.Value) []reflect.Value {
+		out := reflect.New(outType).Elem()
+		for i := range types {
+			out.Field(i + 1).Set(results[i])
+		}
+		mapped := []reflect.Value{out}
+		if hasErr {
+			mapped = append(mapped, results[len(results)-1])
+		}
+		return mapped
+	}, nil
+}
+
+// unwrapTarget returns the function inside an Annotated or an annotated
+// function, for logging.
+func unwrapTarget(fn interface{}) interface{} {
+	switch a := fn.(type) {
+	case Annotated:
+		return a.Target
+	case annotated:
+		return a.Target
+	}
+	return fn
+}
+
+// funcPC returns the entry point of fn, or 0 if fn isn't a function.
+func funcPC(fn interface{}) uintptr {
+	if v := reflect.ValueOf(fn); v.Kind() == reflect.Func {
+		return v.Pointer()
+	}
+	return 0
+}
+
+// relocate rewrites an error that dig returned for wrapper, a function that
+// Fx built with reflect.MakeFunc, to name target, the function it wraps.
+// Dig can only be told where provided functions come from, with
+// dig.LocationForPC, so invoked functions need this instead.
+func relocate(err error, wrapper, target interface{}) error {
+	if err == nil {
+		return nil
+	}
+	from, to := fxreflect.Location(funcPC(wrapper)), fxreflect.Location(funcPC(target))
+	if from == "" || to == "" || from == to {
+		return err
+	}
+	return relocatedError{err: err, from: from, to: to}
+}
+
+type relocatedError struct {
+	err      error
+	from, to string
+}
+
+func (e relocatedError) Error() string {
+	return strings.Replace(e.err.Error(), e.from, e.to, 1)
+}
+
+func (e relocatedError) Unwrap() error { return e.err }
@@ -21,9 +21,14 @@
 package fx_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxtest"
 )
@@ -55,6 +60,50 @@ func TestAnnotated(t *testing.T) {
 		assert.NotNil(t, in.A, "expected in.A to be injected")
 		assert.Equal(t, "foo", in.A.name, "expected to get a type 'a' of name 'foo'")
 	})
+
+	t.Run("ErrorsNameTarget", func(t *testing.T) {
+		type missing struct{}
+		newFromMissing := func(*missing) *a { return &a{} }
+		type named struct {
+			fx.In
+
+			A *a `name:"foo"`
+		}
+		type grouped struct {
+			fx.In
+
+			As []*a `group:"as"`
+		}
+		tests := []struct {
+			desc      string
+			annotated fx.Annotated
+			invoke    interface{}
+		}{
+			{
+				desc:      "Named",
+				annotated: fx.Annotated{Name: "foo", Target: newFromMissing},
+				invoke:    func(named) {},
+			},
+			{
+				desc:      "Grouped",
+				annotated: fx.Annotated{Group: "as", Target: newFromMissing},
+				invoke:    func(grouped) {},
+			},
+			{
+				desc:      "NamedAndGrouped",
+				annotated: fx.Annotated{Name: "foo", Group: "as", Target: newFromMissing},
+				invoke:    func(named) {},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				err := fx.New(fx.NopLogger, fx.Provide(tt.annotated), fx.Invoke(tt.invoke)).Err()
+				require.Error(t, err)
+				assert.Regexp(t, `"go\.uber\.org/fx_test"\.TestAnnotated\.func\d+\.1 \(`, err.Error())
+				assert.NotContains(t, err.Error(), "makeFuncStub")
+			})
+		}
+	})
 }
 
 func TestAnnotatedWrongUsage(t *testing.T) {
@@ -100,3 +149,212 @@ func TestAnnotatedWrongUsage(t *testing.T) {
 		assert.Contains(t, app.Err().Error(), "embeds a dig.In", "expected error when result types were annotated")
 	})
 }
+
+func TestAnnotate(t *testing.T) {
+	type Config struct{ name string }
+	type Conn struct{ cfg *Config }
+
+	newConn := func(cfg *Config) *Conn { return &Conn{cfg: cfg} }
+
+	t.Run("ParamAndResultTags", func(t *testing.T) {
+		type in struct {
+			fx.In
+
+			RO *Conn `name:"ro"`
+			RW *Conn `name:"rw"`
+		}
+
+		var got in
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Name: "ro", Target: func() *Config { return &Config{name: "ro"} }},
+				fx.Annotated{Name: "rw", Target: func() *Config { return &Config{name: "rw"} }},
+				fx.Annotate(newConn, fx.ParamTags(`name:"ro"`), fx.ResultTags(`name:"ro"`)),
+				fx.Annotate(newConn, fx.ParamTags(`name:"rw"`), fx.ResultTags(`name:"rw"`)),
+			),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, "ro", got.RO.cfg.name)
+		assert.Equal(t, "rw", got.RW.cfg.name)
+	})
+
+	t.Run("NamedAndGrouped", func(t *testing.T) {
+		type in struct {
+			fx.In
+
+			RO    *Conn   `name:"ro"`
+			Conns []*Conn `group:"conns"`
+		}
+
+		var got in
+		app := fxtest.New(t,
+			fx.Supply(&Config{}),
+			fx.Provide(fx.Annotate(newConn, fx.ResultTags(`name:"ro" group:"conns"`))),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, []*Conn{got.RO}, got.Conns)
+	})
+
+	t.Run("OptionalAndGroupParams", func(t *testing.T) {
+		var got []string
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Group: "names", Target: func() string { return "a" }},
+				fx.Annotated{Group: "names", Target: func() string { return "b" }},
+			),
+			fx.Invoke(fx.Annotate(func(cfg *Config, names []string) {
+				assert.Nil(t, cfg)
+				got = names
+			}, fx.ParamTags(`optional:"true"`, `group:"names"`))),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.ElementsMatch(t, []string{"a", "b"}, got)
+	})
+
+	t.Run("Variadic", func(t *testing.T) {
+		var got []string
+		app := fxtest.New(t,
+			fx.Provide(fx.Annotated{Group: "names", Target: func() string { return "a" }}),
+			fx.Invoke(fx.Annotate(func(names ...string) { got = names }, fx.ParamTags(`group:"names"`))),
+			fx.Invoke(fx.Annotate(func(prefix string, rest ...*Config) {
+				assert.Empty(t, rest, "untagged variadic parameters are optional")
+			}, fx.ParamTags(`name:"prefix"`))),
+			fx.Provide(fx.Annotated{Name: "prefix", Target: func() string { return ">" }}),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"a"}, got)
+	})
+
+	t.Run("As", func(t *testing.T) {
+		var w io.Writer
+		app := fxtest.New(t,
+			fx.Provide(fx.Annotate(func() *bytes.Buffer { return &bytes.Buffer{} }, fx.As(new(io.Writer)))),
+			fx.Populate(&w),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.IsType(t, &bytes.Buffer{}, w)
+
+		err := fx.New(
+			fx.NopLogger,
+			fx.Provide(fx.Annotate(func() *bytes.Buffer { return &bytes.Buffer{} }, fx.As(new(io.Writer)))),
+			fx.Invoke(func(*bytes.Buffer) {}),
+		).Err()
+		require.Error(t, err, "the concrete type shouldn't be provided")
+	})
+
+	t.Run("ErrorsArePassedThrough", func(t *testing.T) {
+		err := fx.New(
+			fx.NopLogger,
+			fx.Provide(fx.Annotate(func() (*Conn, error) {
+				return nil, errors.New("great sadness")
+			}, fx.ResultTags(`name:"ro"`))),
+			fx.Invoke(fx.Annotate(func(*Conn) {}, fx.ParamTags(`name:"ro"`))),
+		).Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+	})
+
+	t.Run("ErrorsNameTarget", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			opt  fx.Option
+		}{
+			{
+				desc: "Provide",
+				opt: fx.Options(
+					fx.Provide(fx.Annotate(newConn, fx.ResultTags(`name:"ro"`))),
+					fx.Invoke(fx.Annotate(func(*Conn) {}, fx.ParamTags(`name:"ro"`))),
+				),
+			},
+			{
+				desc: "Invoke",
+				opt:  fx.Invoke(fx.Annotate(newConn, fx.ParamTags(`name:"ro"`))),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				err := fx.New(fx.NopLogger, tt.opt).Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), `"go.uber.org/fx_test".TestAnnotate.func1 (`)
+				assert.NotContains(t, err.Error(), "makeFuncStub")
+			})
+		}
+	})
+
+	t.Run("LogsTargetName", func(t *testing.T) {
+		spy := moduleLogSpy{&bytes.Buffer{}}
+		app := fx.New(
+			fx.Logger(spy),
+			fx.Provide(fx.Annotate(newConn, fx.ResultTags(`name:"ro"`))),
+		)
+		require.NoError(t, app.Err())
+		assert.Contains(t, spy.String(), "*fx_test.Conn:ro <= go.uber.org/fx_test.TestAnnotate")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		type in struct{ fx.In }
+		type out struct{ fx.Out }
+
+		tests := []struct {
+			desc string
+			give interface{}
+			want string
+		}{
+			{
+				desc: "not a function",
+				give: fx.Annotate(42, fx.ParamTags(`name:"foo"`)),
+				want: "must be passed a function",
+			},
+			{
+				desc: "too many param tags",
+				give: fx.Annotate(newConn, fx.ParamTags(`name:"a"`, `name:"b"`)),
+				want: "got 2 parameter tags for 1 parameters",
+			},
+			{
+				desc: "too many result tags",
+				give: fx.Annotate(newConn, fx.ResultTags(`name:"a"`, `name:"b"`)),
+				want: "got 2 result tags for 1 results",
+			},
+			{
+				desc: "invalid tag",
+				give: fx.Annotate(newConn, fx.ParamTags(`name=foo`)),
+				want: "invalid tag",
+			},
+			{
+				desc: "ParamTags twice",
+				give: fx.Annotate(newConn, fx.ParamTags(`name:"a"`), fx.ParamTags(`name:"b"`)),
+				want: "cannot apply more than one ParamTags",
+			},
+			{
+				desc: "fx.In parameter",
+				give: fx.Annotate(func(in) *Conn { return nil }, fx.ParamTags(`name:"a"`)),
+				want: "parameter 0 is an fx.In struct",
+			},
+			{
+				desc: "fx.Out result",
+				give: fx.Annotate(func() out { return out{} }, fx.ResultTags(`name:"a"`)),
+				want: "result 0 is an fx.Out struct",
+			},
+			{
+				desc: "As a non-interface",
+				give: fx.Annotate(newConn, fx.As(new(Conn))),
+				want: "expected a pointer to an interface",
+			},
+			{
+				desc: "As an unimplemented interface",
+				give: fx.Annotate(newConn, fx.As(new(fmt.Stringer))),
+				want: "does not implement fmt.Stringer",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				err := fx.New(fx.NopLogger, fx.Provide(tt.give)).Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want)
+			})
+		}
+	})
+}
@@ -25,21 +25,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
 	"reflect"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"go.uber.org/dig"
-	"fx-master/internal/fxlog"
+	"fx-master/fxevent"
 	"fx-master/internal/fxreflect"
 	"fx-master/internal/lifecycle"
 	"go.uber.org/multierr"
 )
 
+var _exit = func(code int) { os.Exit(code) }
+
 // DefaultTimeout is the default timeout for starting or stopping an
 // application. It can be configured with the StartTimeout and StopTimeout
 // options.
@@ -87,6 +89,10 @@ func (f optionFunc) apply(app *App) { f(app) }
 // See the documentation of the In and Out types for advanced features,
 // including optional parameters and named instances.
 //
+// Values provided inside an fx.Module are visible to the whole application
+// unless Private is passed to the same Provide call; see the Private
+// documentation for details.
+//
 // Provide主要用于完成类型注入名称对应的具体实现类构造函数，任意数量个，用来完成最终实例化变量类型。
 // 提供的构造函数有可能会依赖其他类型变量，对于构造函数的返回结果可以是一个对象或多个对象甚至包括error
 // 构造类型 *C 其依赖*A 和 *B: 对应的构造函数 func(*A, *B) (*C, error)
@@ -102,7 +108,19 @@ func Provide(constructors ...interface{}) Option { // 提供构造函数
 type provideOption []interface{}
 
 func (po provideOption) apply(app *App) {  // 新增新的构造函数
-	app.provides = append(app.provides, po...)
+	var private bool
+	for _, c := range po {
+		if _, ok := c.(privateOption); ok {
+			private = true
+		}
+	}
+
+	for _, c := range po {
+		if _, ok := c.(privateOption); ok {
+			continue
+		}
+		app.provides = append(app.provides, provide{target: c, module: app.module, private: private})
+	}
 }
 
 // Options以字符串形式输出
@@ -110,7 +128,11 @@ func (po provideOption) apply(app *App) {  // 新增新的构造函数
 func (po provideOption) String() string {
 	items := make([]string, len(po))
 	for i, c := range po {
-		items[i] = fxreflect.FuncName(c)
+		if _, ok := c.(privateOption); ok {
+			items[i] = "fx.Private"
+			continue
+		}
+		items[i] = fxreflect.FuncName(unwrapTarget(c))
 	}
 	return fmt.Sprintf("fx.Provide(%s)", strings.Join(items, ", "))
 }
@@ -144,13 +166,15 @@ func Invoke(funcs ...interface{}) Option {
 type invokeOption []interface{}
 
 func (io invokeOption) apply(app *App) {
-	app.invokes = append(app.invokes, io...)
+	for _, f := range io {
+		app.invokes = append(app.invokes, invoke{target: f, module: app.module})
+	}
 }
 
 func (io invokeOption) String() string {
 	items := make([]string, len(io))
 	for i, f := range io {
-		items[i] = fxreflect.FuncName(f)
+		items[i] = fxreflect.FuncName(unwrapTarget(f))
 	}
 	return fmt.Sprintf("fx.Invoke(%s)", strings.Join(items, ", "))
 }
@@ -260,6 +284,16 @@ func StopTimeout(v time.Duration) Option {
 	})
 }
 
+// SlowHookThreshold logs a warning for every OnStart or OnStop hook that runs
+// for longer than the given duration. By default, no warnings are logged.
+//
+// hook执行耗时超过该值时记录警告
+func SlowHookThreshold(v time.Duration) Option {
+	return optionFunc(func(app *App) {
+		app.lifecycle.SetSlowThreshold(v)
+	})
+}
+
 // 日志接口
 // Printer is the interface required by Fx's logging backend. It's implemented
 // by most loggers, including the one bundled with the standard library.
@@ -268,13 +302,85 @@ type Printer interface {
 }
 
 // Logger redirects the application's log output to the provided printer.
+// Events are printed as the same "[Fx]" lines as the fxevent.ConsoleLogger
+// writes.
 func Logger(p Printer) Option {
 	return optionFunc(func(app *App) {
-		app.logger = &fxlog.Logger{Printer: p}
-		app.lifecycle = &lifecycleWrapper{lifecycle.New(app.logger)}
+		app.logger = consoleLogger(p)
+		app.logConstructor = nil
+	})
+}
+
+// WithLogger specifies how Fx should build an fxevent.Logger to log its
+// events to. The constructor may depend on any types provided to the
+// application, and must return an fxevent.Logger (and optionally an error).
+// For example,
+//
+//  fx.WithLogger(func(w io.Writer) fxevent.Logger {
+//    return &fxevent.JSONLogger{W: w}
+//  })
+//
+// Events emitted before the logger can be built are buffered and replayed to
+// it once it's ready. If the logger can't be built, the application fails
+// and its events are written to the default logger instead.
+//
+// WithLogger用于指定Fx事件的消费者，其构造函数可以依赖容器中的其他类型；在其构建完成之前产生的事件会先缓存下来再统一交给它处理。
+func WithLogger(constructor interface{}) Option {
+	return optionFunc(func(app *App) {
+		app.logConstructor = constructor
 	})
 }
 
+// consoleLogger writes Fx events to a Printer, one line per Printf call.
+func consoleLogger(p Printer) fxevent.Logger {
+	return &fxevent.ConsoleLogger{W: printerWriter{p}}
+}
+
+type printerWriter struct{ Printer }
+
+func (w printerWriter) Write(b []byte) (int, error) {
+	w.Printf("%s", strings.TrimSuffix(string(b), "\n"))
+	return len(b), nil
+}
+
+// logBuffer holds the events emitted before a custom logger is built.
+type logBuffer struct {
+	events []fxevent.Event
+}
+
+func (b *logBuffer) LogEvent(e fxevent.Event) {
+	b.events = append(b.events, e)
+}
+
+// Flush writes all buffered events to the given logger.
+func (b *logBuffer) Flush(logger fxevent.Logger) {
+	for _, e := range b.events {
+		logger.LogEvent(e)
+	}
+	b.events = nil
+}
+
+// appLogger logs events to the App's current logger, which may change once
+// a custom logger is built.
+type appLogger struct{ app *App }
+
+func (l appLogger) LogEvent(e fxevent.Event) {
+	l.app.logger.LogEvent(e)
+}
+
+// syncLogger serializes calls to a logger that may not be safe for
+// concurrent use.
+type syncLogger struct {
+	mu     sync.Mutex
+	logger fxevent.Logger
+}
+
+func (l *syncLogger) LogEvent(e fxevent.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger.LogEvent(e)
+}
+
 // NopLogger disables the application's log output. Note that this makes some
 // failures difficult to debug, since no errors are printed to console.
 // 禁用application的log输出，同时这也让debug变得困难，由于对应的error不能被打印到console(默认fx日志输出到console)
@@ -339,15 +445,59 @@ type App struct {
 	err          error
 	container    *dig.Container
 	lifecycle    *lifecycleWrapper
-	provides     []interface{}
-	invokes      []interface{}
-	logger       *fxlog.Logger
+	module       *module
+	provides     []provide
+	decorates    []decorate
+	invokes      []invoke
+	logger       fxevent.Logger
+	logConstructor interface{} // passed to WithLogger
 	startTimeout time.Duration
 	stopTimeout  time.Duration
+	drainPeriod  time.Duration
+	drainTimeout time.Duration
+	startReport  LifecycleReport
+	stopReport   LifecycleReport
 	errorHooks   []ErrorHandler
 
-	donesMu sync.RWMutex
-	dones   []chan os.Signal
+	donesMu     sync.RWMutex
+	dones       []chan os.Signal
+	waits       []chan ShutdownSignal
+	signalRelay chan struct{} // closed to stop relaying signals to waits
+
+	pendingShutdown *ShutdownSignal // last shutdown since the app stopped
+	stopped         chan struct{}   // closed when the app next stops
+
+	signals        []os.Signal // signals that end the app
+	signalHandlers *signalHandlers
+	health         *health
+
+	stateMu     sync.Mutex
+	state       State
+	restartable bool
+	drained     bool // whether Drain ran since the last start
+
+	recoverFromPanics bool
+
+	nodes []*depNode // constructors, decorators and invokes seen so far
+
+	groups    orderedGroups
+	autoHooks bool
+}
+
+// provide is a constructor passed to Provide, along with the module it was
+// declared in.
+type provide struct {
+	target   interface{}
+	module   *module
+	private  bool           // visible only inside module, see Private
+	supplied *suppliedValue // non-nil for values passed to Supply
+}
+
+// invoke is a function passed to Invoke, along with the module it was
+// declared in.
+type invoke struct {
+	target interface{}
+	module *module
 }
 
 // ErrorHook registers error handlers that implement error handling functions.
@@ -385,51 +535,144 @@ func (ehl errorHandlerList) HandleError(err error) { // 执行具体的Error处
 //
 // 新建并初始化app，并会立刻执行通过invoke选项注册的函数
 func New(opts ...Option) *App {
-	logger := fxlog.New()   // 日志
-	lc := &lifecycleWrapper{lifecycle.New(logger)} // 将application的lifecycle与logger整合 便于记录application的lifecycle
+	// Validation reports cycles as soon as they're provided, and never calls
+	// constructors or invoked functions.
+	digOpts := []dig.Option{dig.DeferAcyclicVerification()}
+	for _, opt := range opts {
+		if _, ok := opt.(validateOption); ok {
+			digOpts = []dig.Option{dig.DryRun(true)}
+		}
+	}
+	container := dig.New(digOpts...) // 容器
 
 	app := &App{
-		container:    dig.New(dig.DeferAcyclicVerification()),  // 容器
-		lifecycle:    lc,                                       // app生命周期
-		logger:       logger,									// logger
+		container:    container,
+		module:       &module{scope: container},                // 根模块
+		logger:       consoleLogger(log.New(os.Stderr, "", log.LstdFlags)), // 默认日志
 		startTimeout: DefaultTimeout,                           // 启动有效期 (启动app时 完成注册option的执行有效期)
 		stopTimeout:  DefaultTimeout,							// 停止有效期 (停止app时 针对完成注册option处理有效期)
+		drainTimeout: DefaultTimeout,                           // 排空有效期 (停止app前 执行OnDrain的有效期)
+		stopped:      make(chan struct{}),
+		signals:      _defaultSignals,
+		signalHandlers: &signalHandlers{},
 	}
+	app.health = &health{app: app}
+	// 将application的lifecycle与logger整合 便于记录application的lifecycle
+	app.lifecycle = &lifecycleWrapper{Lifecycle: lifecycle.New(appLogger{app})}
 
 	for _, opt := range opts {  // 应用option
 		opt.apply(app)
 	}
 
+	// 自定义logger需要从容器中构建 在此之前的事件先缓存
+	var (
+		fallback fxevent.Logger
+		buffer   *logBuffer
+	)
+	if app.logConstructor != nil {
+		fallback, buffer = app.logger, &logBuffer{}
+		app.logger = buffer
+	}
+
+	if app.err != nil {
+		app.logger.LogEvent(&fxevent.Provided{Err: app.err})
+	}
+
+	// 先找出所有有序消费的group 其成员在provide时需要额外记录顺序
+	for _, p := range app.provides {
+		app.groups.scan(p.target)
+	}
+	for _, d := range app.decorates {
+		app.groups.scan(d.target)
+	}
+	for _, i := range app.invokes {
+		app.groups.scan(i.target)
+	}
+
 	for _, p := range app.provides { // provide构造函数
 		app.provide(p)
 	}
-	// 三个特殊的provide：Lifecycle/shutdowner/dotGraph
-	app.provide(func() Lifecycle { return app.lifecycle })
-	app.provide(app.shutdowner)
-	app.provide(app.dotGraph)
+	// 几个特殊的provide：Lifecycle/shutdowner/SignalHandlers/Health/Runner/dotGraph
+	app.provide(provide{target: func() Lifecycle { return app.lifecycle }, module: app.module})
+	app.provide(provide{target: app.shutdowner, module: app.module})
+	app.provide(provide{target: func() SignalHandlers { return app.signalHandlers }, module: app.module})
+	app.provide(provide{target: func() Health { return app.health }, module: app.module})
+	app.provide(provide{target: func() Runner { return &runner{app: app} }, module: app.module})
+	app.provide(provide{target: app.dotGraph, module: app.module})
+
+	if buffer != nil {
+		app.constructCustomLogger(buffer, fallback)
+	}
+	// Runner workers log from their own goroutines.
+	app.logger = &syncLogger{logger: app.logger}
 
 	if app.err != nil {  // 在App很多内容是以Option提供的 有可能在Option被应用后App出现error 不过这时可以直接返回App 在通过Stop来进行App停止操作
-		app.logger.Printf("Error after options were applied: %v", app.err)
 		return app
 	}
 
 	// 在Option应用过程正常 会对invoke进行执行：通过invoke提供的操作都会被立刻执行 而不会延迟执行
-	if err := app.executeInvokes(); err != nil {
-		app.err = err  // 执行invoke出现error
-
-		if dig.CanVisualizeError(err) {
-			var b bytes.Buffer
-			dig.Visualize(app.container, &b, dig.VisualizeError(err))
-			err = errorWithGraph{
-				graph: b.String(),
-				err:   err,
-			}
-		}
+	err := app.executeDecorates() // decorator需要在invoke之前注册
+	if err == nil {
+		err = app.executeInvokes()
+	}
+	if err != nil {
+		app.err = err  // 执行decorate或invoke出现error
+
+		err = app.withGraph(err)
 		errorHandlerList(app.errorHooks).HandleError(err)  // 使用errorHandlerList中的ErrorHandler对error进行处理
 	}
 	return app
 }
 
+// withGraph attaches a visualization of the dependency graph to errors that
+// dig can visualize.
+func (app *App) withGraph(err error) error {
+	if !dig.CanVisualizeError(err) {
+		return err
+	}
+	var b bytes.Buffer
+	dig.Visualize(app.container, &b, dig.VisualizeError(err))
+	return errorWithGraph{
+		graph: b.String(),
+		err:   err,
+	}
+}
+
+// constructCustomLogger builds the logger passed to WithLogger and replays
+// the buffered events to it. If the logger can't be built, the events go to
+// the fallback logger instead and the application fails.
+func (app *App) constructCustomLogger(buffer *logBuffer, fallback fxevent.Logger) {
+	var (
+		logger fxevent.Logger
+		err    error
+	)
+	if app.err == nil {
+		scope := app.container.Scope("fx.WithLogger")
+		err = scope.Provide(app.logConstructor)
+		if err == nil {
+			err = app.invoke(scope, func(l fxevent.Logger) { logger = l })
+		}
+	}
+
+	if logger == nil {
+		// Either the logger failed, or the application already failed and
+		// the logger's dependencies may be missing.
+		app.logger = fallback
+		buffer.Flush(fallback)
+		if err != nil {
+			fallback.LogEvent(&fxevent.LoggerInitialized{Err: err})
+			app.err = err
+		}
+		return
+	}
+
+	app.logger = logger
+	buffer.Flush(logger)
+	logger.LogEvent(&fxevent.LoggerInitialized{
+		ConstructorName: fxreflect.FuncName(app.logConstructor),
+	})
+}
+
 // DotGraph contains a DOT language visualization of the dependency graph in
 // an Fx application. It is provided in the container by default at
 // initialization. On failure to build the dependency graph, it is attached
@@ -471,17 +714,24 @@ func VisualizeError(err error) (string, error) {
 // configured different timeouts with the StartTimeout or StopTimeout options.
 // It's designed to make typical applications simple to run.
 //
+// Run exits the process once the application has stopped. The exit code is
+// the one given to the Shutdowner with ExitCode, or 1 if the application
+// failed to start or stop cleanly. Run doesn't call os.Exit for a zero exit
+// code.
+//
 // However, all of Run's functionality is implemented in terms of the exported
-// Start, Done, and Stop methods. Applications with more specialized needs
+// Start, Wait, and Stop methods. Applications with more specialized needs
 // can use those methods directly instead of relying on Run.
 
 // 启动application，并阻塞在signal通道上，来优雅的关闭app。
 //
 // 通过使用DefaultTimeout来设置app的启动和关闭deadline，也可以通过StartTimeout和StopTimeout选项来进行设置，DefaultTimeout能够保证app简单执行
 //
-// Run()是整合了Start()、Done()、Stop()的功能，有更特殊需求的app可以直接使用这些方法，而不是依赖于Run
+// Run()是整合了Start()、Wait()、Stop()的功能，有更特殊需求的app可以直接使用这些方法，而不是依赖于Run
 func (app *App) Run() {
-	app.run(app.Done())
+	if code := app.run(app.Wait()); code != 0 {
+		_exit(code)
+	}
 }
 
 // Err returns any error encountered during New's initialization. See the
@@ -515,12 +765,35 @@ func (app *App) Err() error {
 //
 // Note that Start short-circuits immediately if the New constructor
 // encountered any errors in application initialization.
+//
+// Start returns an error if the application has already been started,
+// unless it has since stopped or failed and was built with the Restartable
+// option.
 
 //
 // 启动长时间运行的goroutine，类似network server或消息队列消费，主要是通过与App的Lifecycle进行交互的
 //
 func (app *App) Start(ctx context.Context) error {
-	return withTimeout(ctx, app.start)
+	if err := app.beginStart(); err != nil {
+		return err
+	}
+
+	begin := time.Now()
+	err := withTimeout(ctx, app.start)
+	app.startReport, err = app.lifecycle.report(ctx, "OnStart", app.lifecycle.StartRecords(), begin, err)
+	app.logger.LogEvent(&fxevent.Started{Err: err})
+	if err != nil {
+		// Start rolled back, so Shutdown calls waiting for a stop are done.
+		app.setState(StateFailed)
+		app.markStopped()
+		return err
+	}
+
+	app.setState(StateStarted)
+	app.signalHandlers.start()
+	app.health.start()
+	app.resumeRelay()
+	return nil
 }
 
 // Stop gracefully stops the application. It executes any registered OnStop
@@ -530,12 +803,51 @@ func (app *App) Start(ctx context.Context) error {
 // If the application didn't start cleanly, only hooks whose OnStart phase was
 // called are executed. However, all those hooks are executed, even if some
 // fail.
+//
+// Stop returns an error unless the application has started, successfully or
+// not, since it was last stopped.
+//
+// If the application started and hasn't drained since, Stop drains it first;
+// see Drain. Draining is bounded by ctx as well as the DrainTimeout, so it
+// counts against ctx's deadline, and hooks are stopped even if draining
+// fails or ctx expires.
 func (app *App) Stop(ctx context.Context) error {
-	return withTimeout(ctx, app.lifecycle.Stop)
+	drainErr := app.Drain(ctx)
+	if err := app.beginStop(); err != nil {
+		return err
+	}
+	defer app.markStopped()
+	defer app.setState(StateStopped)
+	app.health.unready()
+
+	begin := time.Now()
+	err := withTimeout(ctx, app.lifecycle.Stop)
+	app.stopReport, err = app.lifecycle.report(ctx, "OnStop", app.lifecycle.StopRecords(), begin, err)
+	app.logger.LogEvent(&fxevent.Stopped{Err: err})
+	return multierr.Append(drainErr, err)
+}
+
+// StartupReport describes the most recent call to Start: how long each
+// OnStart hook took, which hook failed and, if the start timeout expired,
+// which hook was still running.
+//
+// 返回最近一次Start的执行报告：每个OnStart hook的耗时及错误
+func (app *App) StartupReport() LifecycleReport {
+	return app.startReport
+}
+
+// ShutdownReport describes the most recent call to Stop in the same way
+// StartupReport describes Start. If Start failed and rolled back, the hooks
+// it stopped aren't included.
+//
+// 返回最近一次Stop的执行报告
+func (app *App) ShutdownReport() LifecycleReport {
+	return app.stopReport
 }
 
 // Done returns a channel of signals to block on after starting the
-// application. Applications listen for the SIGINT and SIGTERM signals; during
+// application. By default, applications listen for the SIGINT and SIGTERM
+// signals; the Signals option changes which signals they listen for. During
 // development, users can send the application SIGTERM by pressing Ctrl-C in
 // the same terminal as the running process.
 //
@@ -547,10 +859,36 @@ func (app *App) Stop(ctx context.Context) error {
 // 在开发期间可以通过对控制台执行ctrl+c 发送SIGTERM信息，也可以将一个signal通过Shutdown的功能手动广播给所有done channels
 func (app *App) Done() <-chan os.Signal {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	if len(app.signals) > 0 {
+		signal.Notify(c, app.signals...)
+	}
 
 	app.donesMu.Lock()
 	app.dones = append(app.dones, c)
+	if sig := app.pendingShutdown; sig != nil {
+		c <- sig.Signal
+	}
+	app.relaySignals()
+	app.donesMu.Unlock()
+	return c
+}
+
+// Wait returns a channel to block on after starting the application. Like
+// Done, it receives the signals that end the application, along with signals
+// broadcast by the Shutdowner, but it also reports the exit code and reason
+// passed to Shutdown. Signals from the operating system are relayed to Wait
+// channels until the application is stopped.
+//
+// 与Done类似，不过返回的ShutdownSignal同时带有退出码及关闭原因
+func (app *App) Wait() <-chan ShutdownSignal {
+	c := make(chan ShutdownSignal, 1)
+
+	app.donesMu.Lock()
+	app.waits = append(app.waits, c)
+	if sig := app.pendingShutdown; sig != nil {
+		c <- *sig
+	}
+	app.relaySignals()
 	app.donesMu.Unlock()
 	return c
 }
@@ -582,34 +920,92 @@ func (app *App) dotGraph() (DotGraph, error) {
 
 // 添加初始化实例的构造函数 完成注入对象名与其关联具体类
 // 注意：provide接收的是function而非Option
-func (app *App) provide(constructor interface{}) {
+func (app *App) provide(p provide) {
 	if app.err != nil {
 		return
 	}
-	app.logger.PrintProvide(constructor)
+	err := p.module.wrapError(app.provideConstructor(p))
+	app.logger.LogEvent(provideEvent(p, err))
+	if err != nil {
+		app.err = err
+		return
+	}
+}
+
+// provideEvent describes a constructor passed to Provide, or a value passed
+// to Supply, for the application's logger.
+func provideEvent(p provide, err error) fxevent.Event {
+	if v := p.supplied; v != nil {
+		return &fxevent.Supplied{
+			TypeName:   v.typ,
+			CallerName: v.caller,
+			ModuleName: p.module.path(),
+			Err:        err,
+		}
+	}
+
+	constructor := p.target
+	if a, ok := constructor.(annotated); ok {
+		if fn, err := a.build(); err == nil {
+			constructor = fn
+		}
+	}
+	return &fxevent.Provided{
+		ConstructorName: fxreflect.FuncName(unwrapTarget(p.target)),
+		OutputTypeNames: fxreflect.ReturnTypes(unwrapTarget(constructor)),
+		ModuleName:      p.module.path(),
+		Private:         p.private,
+		Err:             err,
+	}
+}
+
+// provideConstructor registers a single constructor with the scope of the
+// module that provided it. Unless the constructor is private, its results are
+// exported to the whole application.
+func (app *App) provideConstructor(p provide) error {
+	constructor := p.target
+	node := newDepNode("constructor", p.module, constructor)
+	node.private = p.private
+	if v := p.supplied; v != nil {
+		node.name, node.file, node.line = fmt.Sprintf("fx.Supply(%v)", v.typ), "", 0
+	}
+	app.track(node)
+	opts := []dig.ProvideOption{
+		dig.Export(!p.private),
+		dig.WithProviderCallback(func(info dig.CallbackInfo) {
+			node.ran(info.Error)
+			app.lifecycle.claim(node)
+		}),
+	}
+
+	if v := p.supplied; v != nil && v.pc != 0 {
+		// Name the call to Supply in errors rather than the generated
+		// constructor.
+		opts = append(opts, dig.LocationForPC(v.pc))
+	}
 
 	if _, ok := constructor.(Option); ok { //
-		app.err = fmt.Errorf("fx.Option should be passed to fx.New directly, not to fx.Provide: fx.Provide received %v", constructor)
-		return
+		return fmt.Errorf("fx.Option should be passed to fx.New directly, not to fx.Provide: fx.Provide received %v", constructor)
+	}
+
+	if a, ok := constructor.(Annotated); ok && len(a.Group) > 0 {
+		// 设置Group时 通过fx.Out结构体提供 以支持同时设置Name或多个Group及有序group
+		constructor = a.annotate()
 	}
 
 	if a, ok := constructor.(Annotated); ok { // Annotated类型
-		var opts []dig.ProvideOption
-		switch {
-		case len(a.Group) > 0 && len(a.Name) > 0:  // Group与Name只能设置其中一个
-			app.err = fmt.Errorf("fx.Annotate may not specify both name and group for %v", constructor)
-			return
-		case len(a.Name) > 0:  // 设置Name
+		if len(a.Name) > 0 {  // 设置Name
 			opts = append(opts, dig.Name(a.Name))
-		case len(a.Group) > 0:  // 设置Group
-			opts = append(opts, dig.Group(a.Group))
-
 		}
+		return app.provideFunc(p.module, a.Target, a.Target, opts) // 向container提供constructor
+	}
 
-		if err := app.container.Provide(a.Target, opts...); err != nil { // 向container提供constructor
-			app.err = err
+	if a, ok := constructor.(annotated); ok { // fx.Annotate包装的普通函数
+		fn, err := a.build()
+		if err != nil {
+			return err
 		}
-		return
+		return app.provideFunc(p.module, fn, a.Target, opts)
 	}
 
 	// 非Annotated 且返回值也不是Annotated
@@ -620,14 +1016,92 @@ func (app *App) provide(constructor interface{}) {
 			t := ft.Out(i)
 
 			if t == reflect.TypeOf(Annotated{}) { // 返回值不能使用Annotated
-				app.err = fmt.Errorf("fx.Annotated should be passed to fx.Provide directly, it should not be returned by the constructor: fx.Provide received %v", constructor)
-				return
+				return fmt.Errorf("fx.Annotated should be passed to fx.Provide directly, it should not be returned by the constructor: fx.Provide received %v", constructor)
 			}
 		}
 	}
 
-	if err := app.container.Provide(constructor); err != nil {  // 向container提供constructor
-		app.err = err
+	return app.provideFunc(p.module, constructor, constructor, opts) // 向container提供constructor
+}
+
+// provideFunc registers a constructor with the module's scope, after
+// adapting its results and parameters for value groups and AutoHooks. Dig
+// reports errors against target, the function that was passed to Provide,
+// rather than the adapted function.
+func (app *App) provideFunc(m *module, fn, target interface{}, opts []dig.ProvideOption) error {
+	fn, err := app.groups.wrapResults(app.wrapAutoHooks(fn, fxreflect.FuncName(target)))
+	if err != nil {
+		return err
+	}
+	if pc := funcPC(target); pc != 0 {
+		// Options given by the caller, like the location of fx.Supply
+		// calls, take precedence.
+		opts = append([]dig.ProvideOption{dig.LocationForPC(pc)}, opts...)
+	}
+	return m.scope.Provide(app.groups.wrapParams(fn), opts...)
+}
+
+// Register decorators with the scopes of the modules that declared them,
+// returning the first error encountered.
+//
+// 将decorator注册到其所属module的scope中
+func (app *App) executeDecorates() error {
+	for _, d := range app.decorates {
+		fn := d.target
+
+		var err error
+		if _, ok := fn.(Option); ok {
+			err = fmt.Errorf("fx.Option should be passed to fx.New directly, not to fx.Decorate: fx.Decorate received %v", fn)
+		} else {
+			node := newDepNode("decorator", d.module, fn)
+			if v := d.replaced; v != nil {
+				node.name, node.file, node.line = fmt.Sprintf("fx.Replace(%v)", v.typ), "", 0
+			}
+			app.track(node)
+			if d.replaced != nil {
+				err = app.checkReplaced(d)
+			}
+			if a, ok := fn.(annotated); ok && err == nil {
+				fn, err = a.build()
+			}
+			if err == nil {
+				err = app.groups.checkDecorator(fn)
+			}
+			if err == nil {
+				// Unlike Provide, dig has no option to locate a decorator, so
+				// its errors name the wrapper of an annotated decorator.
+				err = d.module.scope.Decorate(app.groups.wrapParams(fn), dig.WithDecoratorCallback(func(info dig.CallbackInfo) {
+					node.ran(info.Error)
+					app.lifecycle.claim(node)
+				}))
+			}
+		}
+		err = d.module.wrapError(err)
+
+		app.logger.LogEvent(decorateEvent(d, err))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decorateEvent describes a decorator passed to Decorate, or a value passed
+// to Replace, for the application's logger.
+func decorateEvent(d decorate, err error) fxevent.Event {
+	if v := d.replaced; v != nil {
+		return &fxevent.Replaced{
+			TypeName:   v.typ,
+			CallerName: v.caller,
+			ModuleName: d.module.path(),
+			Err:        err,
+		}
+	}
+	return &fxevent.Decorated{
+		DecoratorName:   fxreflect.FuncName(unwrapTarget(d.target)),
+		OutputTypeNames: fxreflect.ReturnTypes(unwrapTarget(d.target)),
+		ModuleName:      d.module.path(),
+		Err:             err,
 	}
 }
 
@@ -640,42 +1114,79 @@ func (app *App) executeInvokes() error {
 	// TODO: consider taking a context to limit the time spent running invocations.
 	var err error
 
-	for _, fn := range app.invokes {  // 遍历invoke
-		fname := fxreflect.FuncName(fn)  // 通过反射的方式获取完整function的完整路径：类似vender/xxx/xxx/xxx.function()
-		app.logger.Printf("INVOKE\t\t%s", fname)
+	for _, i := range app.invokes {  // 遍历invoke
+		fn := i.target
+		fname := fxreflect.FuncName(unwrapTarget(fn))  // 通过反射的方式获取完整function的完整路径：类似vender/xxx/xxx/xxx.function()
+		app.logger.LogEvent(&fxevent.Invoking{
+			FunctionName: fname,
+			ModuleName:   i.module.path(),
+		})
 
 		if _, ok := fn.(Option); ok { // invoke提供的是function而非Option
 			err = fmt.Errorf("fx.Option should be passed to fx.New directly, not to fx.Invoke: fx.Invoke received %v", fn)
 		} else {
-			err = app.container.Invoke(fn) // container invoke the function
+			node := newDepNode("invoke", i.module, fn)
+			app.track(node)
+			if a, ok := fn.(annotated); ok {
+				fn, err = a.build()
+			}
+			if err == nil {
+				wrapped := app.groups.wrapParams(fn)
+				err = app.invoke(i.module.scope, wrapped) // container invoke the function
+				err = app.groups.explain(relocate(err, wrapped, unwrapTarget(i.target)))
+				node.ran(err)
+				app.lifecycle.claim(node)
+			}
+			if err != nil {
+				err = app.explainPrivate(node, err)
+			}
 		}
 
 		if err != nil {
-			app.logger.Printf("Error during %q invoke: %v", fname, err)
+			err = i.module.wrapError(err)
+			app.logger.LogEvent(&fxevent.InvokeFailed{
+				FunctionName: fname,
+				ModuleName:   i.module.path(),
+				Err:          err,
+			})
 			break
 		}
+
+		app.logger.LogEvent(&fxevent.Invoked{
+			FunctionName: fname,
+			ModuleName:   i.module.path(),
+		})
 	}
 
 	return err
 }
 
 // 启动app执行注入操作  接收signal信号判断是否完成: 等价于OnStart、OnStop的结合体
-func (app *App) run(done <-chan os.Signal) {
+// run returns the code the process should exit with.
+func (app *App) run(done <-chan ShutdownSignal) (exitCode int) {
 	startCtx, cancel := context.WithTimeout(context.Background(), app.StartTimeout()) //
 	defer cancel()
 
 	if err := app.Start(startCtx); err != nil {  // start the application
-		app.logger.Fatalf("ERROR\t\tFailed to start: %v", err)
+		return 1
 	}
 
-	app.logger.PrintSignal(<-done)   // send the done signal ， the app start is completed.
+	// send the done signal ， the app start is completed.
+	sig := <-done
+	app.logger.LogEvent(&fxevent.SignalReceived{Signal: sig.Signal})
+
+	// Drain before the stop timeout starts ticking; Drain has its own.
+	drainErr := app.Drain(context.Background())
 
 	stopCtx, cancel := context.WithTimeout(context.Background(), app.StopTimeout()) // stop the application
 	defer cancel()
 
-	if err := app.Stop(stopCtx); err != nil {  // when the start is completed， the app need to execute stop operation
-		app.logger.Fatalf("ERROR\t\tFailed to stop cleanly: %v", err)
+	if err := app.Stop(stopCtx); err != nil || drainErr != nil {  // when the start is completed， the app need to execute stop operation
+		if sig.ExitCode == 0 {
+			return 1
+		}
 	}
+	return sig.ExitCode
 }
 
 // app启动：
@@ -688,15 +1199,15 @@ func (app *App) start(ctx context.Context) error {
 	// Attempt to start cleanly.
 	if err := app.lifecycle.Start(ctx); err != nil {  // 通过app的lifecycle启动 若是启动失败则进行回滚并记录错误现场
 		// Start failed, roll back.
-		app.logger.Printf("ERROR\t\tStart failed, rolling back: %v", err)
-		if stopErr := app.lifecycle.Stop(ctx); stopErr != nil {  // 通过app的lifecycle进行关闭
-			app.logger.Printf("ERROR\t\tCouldn't rollback cleanly: %v", stopErr)
+		app.logger.LogEvent(&fxevent.RollingBack{StartErr: err})
+		stopErr := app.lifecycle.Stop(ctx) // 通过app的lifecycle进行关闭
+		app.logger.LogEvent(&fxevent.RolledBack{Err: stopErr})
+		if stopErr != nil {
 			return multierr.Append(err, stopErr)
 		}
 		return err
 	}
 
-	app.logger.Printf("RUNNING")
 	return nil
 }
 
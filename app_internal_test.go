@@ -21,23 +21,73 @@
 package fx
 
 import (
-	"os"
+	"context"
+	"errors"
 	"sync"
 	"syscall"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestAppRun(t *testing.T) {
 	app := New()
-	done := make(chan os.Signal)
+	done := make(chan ShutdownSignal)
 
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		app.run(done)
+		assert.Equal(t, 0, app.run(done))
 	}()
 
-	done <- syscall.SIGINT
+	done <- ShutdownSignal{Signal: syscall.SIGINT}
 	wg.Wait()
 }
+
+func TestAppRunExitCode(t *testing.T) {
+	t.Run("FromShutdowner", func(t *testing.T) {
+		var s Shutdowner
+		app := New(NopLogger, Populate(&s))
+		done := app.Wait()
+		go func() {
+			assert.NoError(t, s.Shutdown(ExitCode(3)))
+		}()
+		assert.Equal(t, 3, app.run(done))
+	})
+
+	t.Run("StartFailure", func(t *testing.T) {
+		app := New(NopLogger, Invoke(func(lc Lifecycle) {
+			lc.Append(Hook{OnStart: func(context.Context) error {
+				return errors.New("great sadness")
+			}})
+		}))
+		assert.Equal(t, 1, app.run(make(chan ShutdownSignal)))
+	})
+
+	t.Run("StopFailure", func(t *testing.T) {
+		app := New(NopLogger, Invoke(func(lc Lifecycle) {
+			lc.Append(Hook{OnStop: func(context.Context) error {
+				return errors.New("great sadness")
+			}})
+		}))
+		done := make(chan ShutdownSignal, 1)
+		done <- ShutdownSignal{Signal: syscall.SIGTERM}
+		assert.Equal(t, 1, app.run(done))
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		defer func(exit func(int)) { _exit = exit }(_exit)
+		var code int
+		_exit = func(c int) { code = c }
+
+		var s Shutdowner
+		app := New(NopLogger, Populate(&s), Invoke(func(lc Lifecycle) {
+			lc.Append(Hook{OnStart: func(context.Context) error {
+				return s.Shutdown(ShutdownReason(errors.New("job failed")))
+			}})
+		}))
+		app.Run()
+		assert.Equal(t, 1, code)
+	})
+}
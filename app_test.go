@@ -29,6 +29,7 @@ import (
 	"time"
 
 	. "github.com/uber-go/fx"
+	"github.com/uber-go/fx/fxevent"
 	"github.com/uber-go/fx/fxtest"
 	"go.uber.org/multierr"
 
@@ -89,8 +90,8 @@ func TestNewApp(t *testing.T) {
 
 		errMsg := err.Error()
 		assert.Contains(t, errMsg, "cycle detected in dependency graph")
-		assert.Contains(t, errMsg, "depends on fx_test.A")
-		assert.Contains(t, errMsg, "depends on fx_test.B")
+		assert.Contains(t, errMsg, "depends on func(fx_test.B) fx_test.A")
+		assert.Contains(t, errMsg, "depends on func(fx_test.A) fx_test.B")
 	})
 
 	t.Run("ProvidesDotGraph", func(t *testing.T) {
@@ -174,21 +175,33 @@ func TestNewApp(t *testing.T) {
 		require.NoError(t, app.Err())
 	})
 
-	t.Run("CannotNameAndGroup", func(t *testing.T) {
+	t.Run("NameAndGroup", func(t *testing.T) {
 		type A struct{}
 
+		var calls int
+		var got struct {
+			In
+
+			Named *A   `name:"foo"`
+			Bar   []*A `group:"bar"`
+			Baz   []*A `group:"baz"`
+		}
 		app := NewForTest(t,
 			Provide(
 				Annotated{
-					Target: func() A { return A{} },
+					Target: func() *A { calls++; return &A{} },
 					Name:   "foo",
-					Group:  "bar",
+					Group:  "bar,baz",
 				},
 			),
+			Populate(&got),
 		)
+		require.NoError(t, app.Err())
 
-		err := app.Err()
-		require.Error(t, err)
+		assert.Equal(t, 1, calls)
+		require.Len(t, got.Bar, 1)
+		require.Len(t, got.Baz, 1)
+		assert.True(t, got.Named == got.Bar[0] && got.Named == got.Baz[0], "expected a single shared instance")
 	})
 }
 
@@ -208,7 +221,7 @@ func TestInvokes(t *testing.T) {
 		)
 		err := app.Err()
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "fx_test.A is not in the container")
+		assert.Contains(t, err.Error(), "missing type: fx_test.A")
 	})
 
 	t.Run("ErrorHooksAreCalled", func(t *testing.T) {
@@ -428,6 +441,30 @@ func TestAppStart(t *testing.T) {
 		err := app.Start(ctx)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "context deadline exceeded")
+		assert.Regexp(t, `OnStart hook added by \S+TestAppStart`, err.Error())
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected a deadline error")
+
+		report := app.StartupReport()
+		require.Len(t, report.Hooks, 1)
+		assert.Equal(t, context.DeadlineExceeded, report.Hooks[0].Err)
+		assert.Equal(t, err, report.Err)
+	})
+
+	t.Run("HookTimeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		app := fxtest.New(t,
+			Invoke(func(lc Lifecycle) {
+				lc.Append(Hook{
+					OnStart:        func(context.Context) error { <-release; return nil },
+					OnStartTimeout: 5 * time.Millisecond,
+				})
+			}),
+		)
+
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Regexp(t, `OnStart hook for \S+TestAppStart\S* exceeded its 5ms timeout`, err.Error())
 	})
 
 	t.Run("StartError", func(t *testing.T) {
@@ -578,6 +615,75 @@ func TestAppStop(t *testing.T) {
 	})
 }
 
+func TestAppendStarted(t *testing.T) {
+	t.Run("AppendedDuringStart", func(t *testing.T) {
+		var events []string
+		record := func(name string) Hook {
+			return Hook{
+				OnStart: func(context.Context) error { events = append(events, "start "+name); return nil },
+				OnStop:  func(context.Context) error { events = append(events, "stop "+name); return nil },
+			}
+		}
+		app := fxtest.New(t, Invoke(func(lc Lifecycle) {
+			lc.Append(Hook{OnStart: func(context.Context) error {
+				lc.Append(record("inner"))
+				return nil
+			}})
+			lc.Append(record("outer"))
+		}))
+		app.RequireStart().RequireStop()
+		assert.Equal(t, []string{
+			"start outer", "start inner",
+			"stop inner", "stop outer",
+		}, events)
+	})
+
+	t.Run("AfterStart", func(t *testing.T) {
+		var (
+			lc     Lifecycle
+			events []string
+		)
+		app := fxtest.New(t, Populate(&lc), Invoke(func(lc Lifecycle) {
+			lc.Append(Hook{OnStop: func(context.Context) error {
+				events = append(events, "stop eager")
+				return nil
+			}})
+		}))
+		app.RequireStart()
+
+		require.NoError(t, AppendStarted(context.Background(), lc, Hook{
+			OnStart: func(context.Context) error { events = append(events, "start lazy"); return nil },
+			OnStop:  func(context.Context) error { events = append(events, "stop lazy"); return nil },
+		}))
+		assert.Equal(t, []string{"start lazy"}, events)
+
+		app.RequireStop()
+		assert.Equal(t, []string{"start lazy", "stop lazy", "stop eager"}, events)
+	})
+
+	t.Run("StartError", func(t *testing.T) {
+		var lc Lifecycle
+		app := fxtest.New(t, Populate(&lc))
+		app.RequireStart()
+
+		err := AppendStarted(context.Background(), lc, Hook{
+			OnStart: func(context.Context) error { return errors.New("lazy fail") },
+		})
+		assert.EqualError(t, err, "lazy fail")
+		app.RequireStop()
+	})
+
+	t.Run("TestLifecycle", func(t *testing.T) {
+		lc := fxtest.NewLifecycle(t).RequireStart()
+		started := false
+		require.NoError(t, AppendStarted(context.Background(), lc, Hook{
+			OnStart: func(context.Context) error { started = true; return nil },
+		}))
+		assert.True(t, started)
+		lc.RequireStop()
+	})
+}
+
 func TestDone(t *testing.T) {
 	done := fxtest.New(t).Done()
 	require.NotNil(t, done, "Got a nil channel.")
@@ -600,6 +706,169 @@ func TestNopLogger(t *testing.T) {
 	app.RequireStart().RequireStop()
 }
 
+func TestLifecycleReports(t *testing.T) {
+	t.Run("RecordsEachHook", func(t *testing.T) {
+		app := fxtest.New(t, Invoke(func(lc Lifecycle) {
+			lc.Append(Hook{
+				OnStart: func(context.Context) error {
+					time.Sleep(10 * time.Millisecond)
+					return nil
+				},
+				OnStop: func(context.Context) error { return errors.New("great sadness") },
+			})
+			lc.Append(Hook{OnStart: func(context.Context) error { return nil }})
+		}))
+		app.RequireStart()
+
+		start := app.StartupReport()
+		require.NoError(t, start.Err)
+		require.Len(t, start.Hooks, 2)
+		assert.Contains(t, start.Hooks[0].CallerName, "TestLifecycleReports")
+		assert.Contains(t, start.Hooks[0].FunctionName, "TestLifecycleReports")
+		assert.True(t, start.Hooks[0].Runtime >= 10*time.Millisecond, "runtime too short: %v", start.Hooks[0].Runtime)
+		assert.True(t, start.Runtime >= start.Hooks[0].Runtime, "total runtime shorter than a hook")
+
+		require.Error(t, app.Stop(context.Background()))
+		stop := app.ShutdownReport()
+		require.Len(t, stop.Hooks, 1)
+		assert.EqualError(t, stop.Hooks[0].Err, "great sadness")
+		assert.EqualError(t, stop.Err, "great sadness")
+	})
+
+	t.Run("StopTimeout", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+
+		app := New(NopLogger, Invoke(func(lc Lifecycle) {
+			lc.Append(Hook{OnStop: func(context.Context) error {
+				<-release
+				return nil
+			}})
+		}))
+		require.NoError(t, app.Start(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		err := app.Stop(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "OnStop hook added by")
+
+		stop := app.ShutdownReport()
+		require.Len(t, stop.Hooks, 1)
+		assert.Equal(t, context.DeadlineExceeded, stop.Hooks[0].Err)
+	})
+
+	t.Run("SlowHookThreshold", func(t *testing.T) {
+		spy := &eventSpy{}
+		app := fxtest.New(t,
+			WithLogger(func() fxevent.Logger { return spy }),
+			SlowHookThreshold(time.Millisecond),
+			Invoke(func(lc Lifecycle) {
+				lc.Append(Hook{OnStart: func(context.Context) error {
+					time.Sleep(5 * time.Millisecond)
+					return nil
+				}})
+			}),
+		)
+		app.RequireStart().RequireStop()
+
+		var slow []*fxevent.SlowHook
+		for _, e := range spy.events {
+			if s, ok := e.(*fxevent.SlowHook); ok {
+				slow = append(slow, s)
+			}
+		}
+		require.Len(t, slow, 1)
+		assert.Equal(t, "OnStart", slow[0].Hook)
+		assert.Equal(t, time.Millisecond, slow[0].Threshold)
+	})
+}
+
+type eventSpy struct {
+	events []fxevent.Event
+}
+
+func (s *eventSpy) LogEvent(e fxevent.Event) {
+	s.events = append(s.events, e)
+}
+
+// has reports whether an event of the same type as want was logged.
+func (s *eventSpy) has(want fxevent.Event) bool {
+	for _, e := range s.events {
+		if fmt.Sprintf("%T", e) == fmt.Sprintf("%T", want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Run("ReplaysEarlierEvents", func(t *testing.T) {
+		spy := &eventSpy{}
+		app := fxtest.New(t,
+			Provide(func() *bytes.Buffer { return &bytes.Buffer{} }),
+			WithLogger(func() fxevent.Logger { return spy }),
+		)
+
+		require.NotEmpty(t, spy.events)
+		provided, ok := spy.events[0].(*fxevent.Provided)
+		require.True(t, ok, "expected first event to be Provided, got %T", spy.events[0])
+		assert.Equal(t, []string{"*bytes.Buffer"}, provided.OutputTypeNames)
+
+		init, ok := spy.events[len(spy.events)-1].(*fxevent.LoggerInitialized)
+		require.True(t, ok, "expected last event to be LoggerInitialized")
+		assert.NoError(t, init.Err)
+		assert.Contains(t, init.ConstructorName, "TestWithLogger")
+
+		app.RequireStart().RequireStop()
+		assert.True(t, spy.has(&fxevent.Started{}))
+		assert.True(t, spy.has(&fxevent.Stopped{}))
+	})
+
+	t.Run("DependsOnContainer", func(t *testing.T) {
+		var buf bytes.Buffer
+		app := fxtest.New(t,
+			Supply(&buf),
+			WithLogger(func(b *bytes.Buffer) fxevent.Logger {
+				return &fxevent.JSONLogger{W: b}
+			}),
+			Invoke(func() {}),
+		)
+		app.RequireStart().RequireStop()
+
+		out := buf.String()
+		assert.Contains(t, out, `"event":"Supplied"`)
+		assert.Contains(t, out, `"event":"LoggerInitialized"`)
+		assert.Contains(t, out, `"event":"Invoked"`)
+		assert.Contains(t, out, `"event":"Started"`)
+	})
+
+	t.Run("ConstructorFails", func(t *testing.T) {
+		spy := printerSpy{&bytes.Buffer{}}
+		app := New(
+			Logger(spy),
+			WithLogger(func() (fxevent.Logger, error) {
+				return nil, errors.New("great sadness")
+			}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Contains(t, spy.String(), "Failed to initialize custom logger")
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		spy := printerSpy{&bytes.Buffer{}}
+		app := New(
+			Logger(spy),
+			WithLogger(func(*bytes.Buffer) fxevent.Logger { return fxevent.NopLogger }),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing type: *bytes.Buffer")
+	})
+}
+
 type testErrorWithGraph struct {
 	graph string
 }
@@ -0,0 +1,104 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"strings"
+
+	"fx-master/internal/fxreflect"
+)
+
+// Decorate registers any number of decorator functions. A decorator wraps or
+// replaces a value that's already provided to the application: it takes the
+// current value of one or more types (along with any other dependencies it
+// needs) and returns their replacements. For example,
+//
+//  // Prefixes every message logged by the *log.Logger.
+//  func(l *log.Logger) *log.Logger {
+//    return log.New(l.Writer(), "[server] ", l.Flags())
+//  }
+//
+//  // Adds middleware to the http.Handler, and may fail.
+//  func(h http.Handler, m *Metrics) (http.Handler, error)
+//
+// Consumers of a decorated type receive the value returned by the decorator
+// instead of the original one. Like constructors, decorators are called
+// lazily and at most once.
+//
+// Named values and value groups are decorated by accepting an fx.In struct
+// and returning an fx.Out struct whose fields carry the same name or group
+// tags. A decorator for a value group receives all members of the group and
+// returns the complete replacement set:
+//
+//  type params struct {
+//    fx.In
+//
+//    Handlers []http.Handler `group:"routes"`
+//  }
+//
+//  type result struct {
+//    fx.Out
+//
+//    Handlers []http.Handler `group:"routes"`
+//  }
+//
+//  fx.Decorate(func(p params) result {
+//    ...
+//  })
+//
+// Decorators are scoped to the fx.Module they're declared in: only that
+// module's constructors and invocations, and those of modules nested inside
+// it, see the decorated values. Decorators passed directly to fx.New apply to
+// the whole application. A type may be decorated at most once per module.
+//
+// Errors returned by decorators are reported the same way as invocation
+// errors, including to any registered ErrorHook.
+//
+// Decorate用于对已经Provide的类型进行包装或替换(例如给*log.Logger或http.Handler增加中间件)，而无需修改原有的构造函数；
+// 在其所属Module(及子Module)范围内生效，出现的error与invoke一样会交给ErrorHook处理。
+func Decorate(decorators ...interface{}) Option {
+	return decorateOption(decorators)
+}
+
+type decorateOption []interface{}
+
+func (do decorateOption) apply(app *App) {
+	for _, d := range do {
+		app.decorates = append(app.decorates, decorate{target: d, module: app.module})
+	}
+}
+
+func (do decorateOption) String() string {
+	items := make([]string, len(do))
+	for i, d := range do {
+		items[i] = fxreflect.FuncName(unwrapTarget(d))
+	}
+	return fmt.Sprintf("fx.Decorate(%s)", strings.Join(items, ", "))
+}
+
+// decorate is a function passed to Decorate, along with the module it was
+// declared in.
+type decorate struct {
+	target   interface{}
+	module   *module
+	replaced *suppliedValue // non-nil for values passed to Replace
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestDecorate(t *testing.T) {
+	type Logger struct{ name string }
+
+	t.Run("ReplacesValue", func(t *testing.T) {
+		var l *Logger
+		app := fxtest.New(t,
+			fx.Provide(func() *Logger { return &Logger{name: "root"} }),
+			fx.Decorate(func(l *Logger) *Logger {
+				return &Logger{name: l.name + ".decorated"}
+			}),
+			fx.Populate(&l),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, "root.decorated", l.name)
+	})
+
+	t.Run("UsesOtherDependencies", func(t *testing.T) {
+		type Config struct{ prefix string }
+
+		var l *Logger
+		app := fxtest.New(t,
+			fx.Provide(
+				func() *Logger { return &Logger{name: "root"} },
+				func() Config { return Config{prefix: "cfg"} },
+			),
+			fx.Decorate(func(l *Logger, c Config) *Logger {
+				return &Logger{name: c.prefix + "." + l.name}
+			}),
+			fx.Populate(&l),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, "cfg.root", l.name)
+	})
+
+	t.Run("NamedValues", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			L *Logger `name:"foo"`
+		}
+		type result struct {
+			fx.Out
+
+			L *Logger `name:"foo"`
+		}
+
+		var got params
+		app := fxtest.New(t,
+			fx.Provide(fx.Annotated{
+				Name:   "foo",
+				Target: func() *Logger { return &Logger{name: "foo"} },
+			}),
+			fx.Decorate(func(p params) result {
+				return result{L: &Logger{name: p.L.name + ".decorated"}}
+			}),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, "foo.decorated", got.L.name)
+	})
+
+	t.Run("ValueGroups", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Loggers []*Logger `group:"loggers"`
+		}
+		type result struct {
+			fx.Out
+
+			Loggers []*Logger `group:"loggers"`
+		}
+
+		var got params
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{
+					Group:  "loggers",
+					Target: func() *Logger { return &Logger{name: "a"} },
+				},
+				fx.Annotated{
+					Group:  "loggers",
+					Target: func() *Logger { return &Logger{name: "b"} },
+				},
+			),
+			fx.Decorate(func(p params) result {
+				return result{Loggers: append(p.Loggers, &Logger{name: "c"})}
+			}),
+			fx.Populate(&got),
+		)
+		defer app.RequireStart().RequireStop()
+
+		var names []string
+		for _, l := range got.Loggers {
+			names = append(names, l.name)
+		}
+		sort.Strings(names)
+		assert.Equal(t, []string{"a", "b", "c"}, names)
+	})
+
+	t.Run("ScopedToModule", func(t *testing.T) {
+		var inside, outside *Logger
+		app := fxtest.New(t,
+			fx.Provide(func() *Logger { return &Logger{name: "root"} }),
+			fx.Module("child",
+				fx.Decorate(func(l *Logger) *Logger {
+					return &Logger{name: l.name + ".child"}
+				}),
+				fx.Module("grandchild",
+					fx.Invoke(func(l *Logger) { inside = l }),
+				),
+			),
+			fx.Invoke(func(l *Logger) { outside = l }),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, "root.child", inside.name)
+		assert.Equal(t, "root", outside.name)
+	})
+
+	t.Run("DecoratorErrorIsReportedToErrorHook", func(t *testing.T) {
+		var hookErr error
+		app := fx.New(
+			fx.NopLogger,
+			fx.ErrorHook(errHandlerFunc(func(err error) { hookErr = err })),
+			fx.Provide(func() *Logger { return &Logger{} }),
+			fx.Module("foo",
+				fx.Decorate(func(*Logger) (*Logger, error) {
+					return nil, errors.New("great sadness")
+				}),
+				fx.Invoke(func(*Logger) {}),
+			),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `module "foo": `)
+		assert.Contains(t, err.Error(), "great sadness")
+		require.Error(t, hookErr)
+		assert.Contains(t, hookErr.Error(), "great sadness")
+	})
+
+	t.Run("DecoratingTwiceFails", func(t *testing.T) {
+		var hookErr error
+		app := fx.New(
+			fx.NopLogger,
+			fx.ErrorHook(errHandlerFunc(func(err error) { hookErr = err })),
+			fx.Provide(func() *Logger { return &Logger{} }),
+			fx.Decorate(
+				func(l *Logger) *Logger { return l },
+				func(l *Logger) *Logger { return l },
+			),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "already decorated")
+		assert.Equal(t, err, hookErr)
+	})
+
+	t.Run("DecoratingAnOptionFails", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Decorate(fx.Provide(func() *Logger { return &Logger{} })),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "fx.Option should be passed to fx.New directly, not to fx.Decorate")
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t,
+			"fx.Decorate(bytes.NewBuffer(), strings.NewReader())",
+			fmt.Sprint(fx.Decorate(bytes.NewBuffer, strings.NewReader)),
+		)
+	})
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fx-master/fxevent"
+)
+
+// DrainPeriod makes a started application wait for the given duration
+// between the moment it stops being ready and the moment it runs its OnDrain
+// hooks, giving load balancers time to notice that it's going away. See
+// App.Drain.
+//
+// 停止前的排空期：就绪状态变为false后等待该时长 再执行OnDrain hook
+func DrainPeriod(v time.Duration) Option {
+	return optionFunc(func(app *App) {
+		app.drainPeriod = v
+	})
+}
+
+// DrainTimeout changes how long the application may spend draining,
+// including the DrainPeriod. Apps default to using DefaultTimeout.
+//
+// 排空阶段(包括DrainPeriod)的有效时间；默认使用DefaultTimeout
+func DrainTimeout(v time.Duration) Option {
+	return optionFunc(func(app *App) {
+		app.drainTimeout = v
+	})
+}
+
+// DrainTimeout returns the configured drain timeout. Apps default to using
+// DefaultTimeout, but users can configure this behavior using the
+// DrainTimeout option.
+func (app *App) DrainTimeout() time.Duration {
+	return app.drainTimeout
+}
+
+// Drain prepares a started application to stop. The application stops being
+// ready right away (see Health), waits for the DrainPeriod, and then runs
+// the OnDrain hooks of the hooks that started, in reverse order, so that
+// components can finish their in-flight work. Draining gives up once ctx or
+// the DrainTimeout expires, whichever comes first.
+//
+// Run drains the application as soon as it receives a signal, before the
+// StopTimeout starts. Stop drains the application first unless Drain was
+// already called since it started, so most applications don't need to call
+// Drain directly; those that do must let it return before calling Stop.
+//
+// Drain does nothing unless the application has started, and only drains an
+// application once per start. Draining is skipped entirely, and not logged,
+// if there's no DrainPeriod and no OnDrain hooks.
+//
+// 停止app之前的排空阶段：先标记为未就绪，等待DrainPeriod，然后逆序执行OnDrain hook
+func (app *App) Drain(ctx context.Context) error {
+	if !app.beginDrain() {
+		return nil
+	}
+	app.health.unready()
+
+	if app.drainPeriod <= 0 && !app.lifecycle.HasDrainHooks() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, app.drainTimeout)
+	defer cancel()
+
+	app.logger.LogEvent(&fxevent.Draining{Period: app.drainPeriod})
+	begin := time.Now()
+	err := withTimeout(ctx, app.drain)
+	if err != nil && err == ctx.Err() {
+		if rec, ok := app.lifecycle.Running(); ok {
+			err = fmt.Errorf("OnDrain hook added by %v did not finish after %v: %w",
+				rec.CallerName, rec.Runtime, err)
+		}
+	}
+	app.logger.LogEvent(&fxevent.Drained{Runtime: time.Since(begin), Err: err})
+	return err
+}
+
+func (app *App) drain(ctx context.Context) error {
+	if app.drainPeriod > 0 {
+		timer := time.NewTimer(app.drainPeriod)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return app.lifecycle.Drain(ctx)
+}
+
+// beginDrain reports whether the application is started and hasn't drained
+// since it started, and marks it as drained.
+func (app *App) beginDrain() bool {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	if app.state != StateStarted || app.drained {
+		return false
+	}
+	app.drained = true
+	return true
+}
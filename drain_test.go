@@ -0,0 +1,191 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestDrain(t *testing.T) {
+	t.Run("BeforeStop", func(t *testing.T) {
+		var (
+			events []string
+			health fx.Health
+		)
+		hook := func(name string) fx.Hook {
+			return fx.Hook{
+				OnStart: func(context.Context) error { return nil },
+				OnDrain: func(ctx context.Context) error {
+					assert.False(t, health.Status(ctx).Ready, "must not be ready while draining")
+					events = append(events, "drain "+name)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					events = append(events, "stop "+name)
+					return nil
+				},
+			}
+		}
+		app := fxtest.New(t,
+			fx.Populate(&health),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(hook("a"))
+				lc.Append(hook("b"))
+			}),
+		)
+		app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"drain b", "drain a", "stop b", "stop a"}, events)
+	})
+
+	t.Run("OncePerStart", func(t *testing.T) {
+		drains := 0
+		app := fxtest.New(t, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{OnDrain: func(context.Context) error {
+				drains++
+				return nil
+			}})
+		}))
+		require.NoError(t, app.Drain(context.Background()), "not started yet")
+		assert.Zero(t, drains)
+
+		app.RequireStart()
+		require.NoError(t, app.Drain(context.Background()))
+		require.NoError(t, app.Drain(context.Background()))
+		app.RequireStop()
+		assert.Equal(t, 1, drains)
+	})
+
+	t.Run("Period", func(t *testing.T) {
+		spy := &eventSpy{}
+		app := fx.New(
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.DrainPeriod(20*time.Millisecond),
+		)
+		require.NoError(t, app.Start(context.Background()))
+
+		begin := time.Now()
+		require.NoError(t, app.Stop(context.Background()))
+		assert.True(t, time.Since(begin) >= 20*time.Millisecond, "must wait for the drain period")
+
+		var drained *fxevent.Drained
+		for _, e := range spy.events {
+			switch e := e.(type) {
+			case *fxevent.Draining:
+				assert.Equal(t, 20*time.Millisecond, e.Period)
+			case *fxevent.Drained:
+				drained = e
+			case *fxevent.Stopped:
+				assert.NotNil(t, drained, "must drain before stopping")
+			}
+		}
+		require.NotNil(t, drained)
+		assert.NoError(t, drained.Err)
+	})
+
+	t.Run("PeriodOutsideStopTimeout", func(t *testing.T) {
+		var stopErr error
+		app := fxtest.New(t,
+			fx.StopTimeout(30*time.Millisecond),
+			fx.DrainPeriod(50*time.Millisecond),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{OnStop: func(ctx context.Context) error {
+					stopErr = ctx.Err()
+					return nil
+				}})
+			}),
+		)
+		app.RequireStart()
+
+		// Like Run, drain before the stop timeout starts.
+		require.NoError(t, app.Drain(context.Background()))
+		app.RequireStop()
+		assert.NoError(t, stopErr, "draining must not use up the stop timeout")
+	})
+
+	t.Run("StopBoundsDrain", func(t *testing.T) {
+		spy := &eventSpy{}
+		app := fx.New(
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.DrainPeriod(time.Minute),
+		)
+		require.NoError(t, app.Start(context.Background()))
+
+		// An expired stop context cuts the drain period short.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := app.Stop(ctx)
+		assert.True(t, errors.Is(err, context.Canceled), "got %v", err)
+
+		var drained *fxevent.Drained
+		for _, e := range spy.events {
+			if e, ok := e.(*fxevent.Drained); ok {
+				drained = e
+			}
+		}
+		require.NotNil(t, drained)
+		assert.True(t, errors.Is(drained.Err, context.Canceled), "got %v", drained.Err)
+	})
+
+	t.Run("SkippedWithoutHooks", func(t *testing.T) {
+		spy := &eventSpy{}
+		app := fx.New(fx.WithLogger(func() fxevent.Logger { return spy }))
+		require.NoError(t, app.Start(context.Background()))
+		require.NoError(t, app.Stop(context.Background()))
+		assert.False(t, spy.has(&fxevent.Draining{}))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		stopped := false
+		block := make(chan struct{})
+		defer close(block)
+		app := fxtest.New(t,
+			fx.DrainTimeout(10*time.Millisecond),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{
+					OnDrain: func(context.Context) error {
+						<-block
+						return nil
+					},
+					OnStop: func(context.Context) error {
+						stopped = true
+						return nil
+					},
+				})
+			}),
+		)
+		app.RequireStart()
+
+		err := app.Stop(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "OnDrain hook added by")
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.True(t, stopped, "hooks must stop even if draining fails")
+	})
+}
@@ -193,7 +193,7 @@ func NewHandler(logger *log.Logger) (http.Handler, error) {
 }
 
 // http.ServeMux构造函数
-func NewMux(lc fx.Lifecycle, logger *log.Logger) *http.ServeMux {
+func NewMux(runner fx.Runner, logger *log.Logger) (*http.ServeMux, error) {
 	logger.Print("Executing NewMux.")
 
 	mux := http.NewServeMux()
@@ -202,19 +202,25 @@ func NewMux(lc fx.Lifecycle, logger *log.Logger) *http.ServeMux {
 		Handler: mux,
 	}
 
-	lc.Append(fx.Hook{ // 使用Hook 重新实现OnStart和OnStop
-		OnStart: func(context.Context) error {
-			logger.Print("Starting HTTP server.")
-			go server.ListenAndServe()
-			return nil
-		},
-		OnStop: func(ctx context.Context) error {
+	// 由Runner管理后台任务: ListenAndServe失败时app会随之关闭 停止时取消ctx并等待其退出
+	err := runner.Go("http", func(ctx context.Context) error {
+		logger.Print("Starting HTTP server.")
+		shutdown := make(chan error, 1)
+		go func() {
+			<-ctx.Done()
 			logger.Print("Stopping HTTP server.")
-			return server.Shutdown(ctx)
-		},
+			// 优雅关闭: 等待处理中的请求完成 最多10s
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			shutdown <- server.Shutdown(shutdownCtx)
+		}()
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		// ListenAndServe returns as soon as Shutdown begins.
+		return <-shutdown
 	})
-
-	return mux
+	return mux, err
 }
 
 // 注册http.Handler
@@ -224,6 +230,12 @@ func Register(mux *http.ServeMux, h http.Handler) {
 	fmt.Println("Register end")
 }
 
+// 注册健康检查: app启动完成后/health返回200 开始停止后返回503
+func RegisterHealth(mux *http.ServeMux, health fx.Health) {
+	mux.Handle("/health", health)
+	mux.Handle("/health/live", health.LivenessHandler())
+}
+
 func test6(){
 	app := fx.New(
 		fx.Provide(
@@ -231,7 +243,7 @@ func test6(){
 			NewHandler,
 			NewMux,
 		),
-		fx.Invoke(Register),
+		fx.Invoke(Register, RegisterHealth),
 	)
 	startCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -242,6 +254,10 @@ func test6(){
 	//app.Run()
 
 	http.Get("http://localhost:8080/")
+	if resp, err := http.Get("http://localhost:8080/health"); err == nil {
+		io.Copy(os.Stdout, resp.Body)
+		resp.Body.Close()
+	}
 
 	stopCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
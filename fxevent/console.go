@@ -0,0 +1,130 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxevent
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ConsoleLogger writes events to a Writer as the human-readable "[Fx]" lines
+// Fx has always logged.
+type ConsoleLogger struct {
+	W io.Writer
+}
+
+var _ Logger = (*ConsoleLogger)(nil)
+
+func (l *ConsoleLogger) logf(msg string, args ...interface{}) {
+	fmt.Fprintf(l.W, "[Fx] "+msg+"\n", args...)
+}
+
+// LogEvent writes the event as zero or more lines.
+func (l *ConsoleLogger) LogEvent(event Event) {
+	switch e := event.(type) {
+	case *LoggerInitialized:
+		if e.Err != nil {
+			l.logf("ERROR\t\tFailed to initialize custom logger: %v", e.Err)
+		} else {
+			l.logf("LOGGER\tInitialized custom logger from %s", e.ConstructorName)
+		}
+	case *Provided:
+		for _, rtype := range e.OutputTypeNames {
+			l.logf("PROVIDE\t%s <= %s%s", rtype, e.ConstructorName, fromModule(e.ModuleName))
+		}
+		if e.Err != nil {
+			l.logf("Error after options were applied: %v", e.Err)
+		}
+	case *Supplied:
+		l.logf("SUPPLY\t%s <= %s%s", e.TypeName, e.CallerName, fromModule(e.ModuleName))
+		if e.Err != nil {
+			l.logf("Error after options were applied: %v", e.Err)
+		}
+	case *Decorated:
+		for _, rtype := range e.OutputTypeNames {
+			l.logf("DECORATE\t%s <= %s%s", rtype, e.DecoratorName, fromModule(e.ModuleName))
+		}
+		if e.Err != nil {
+			l.logf("Error during %q decorate: %v", e.DecoratorName, e.Err)
+		}
+	case *Replaced:
+		l.logf("REPLACE\t%s <= %s%s", e.TypeName, e.CallerName, fromModule(e.ModuleName))
+		if e.Err != nil {
+			l.logf("Error during %q replace: %v", e.TypeName, e.Err)
+		}
+	case *Invoking:
+		l.logf("INVOKE\t\t%s%s", e.FunctionName, fromModule(e.ModuleName))
+	case *InvokeFailed:
+		l.logf("Error during %q invoke: %v", e.FunctionName, e.Err)
+	case *OnStartExecuting:
+		l.logf("START\t\t%s()", e.CallerName)
+	case *OnDrainExecuting:
+		l.logf("DRAIN\t\t%s()", e.CallerName)
+	case *OnStopExecuting:
+		l.logf("STOP\t\t%s()", e.CallerName)
+	case *SlowHook:
+		l.logf("WARN\t\t%s hook added by %s() took %v, longer than %v", e.Hook, e.CallerName, e.Runtime, e.Threshold)
+	case *Started:
+		if e.Err != nil {
+			l.logf("ERROR\t\tFailed to start: %v", e.Err)
+		} else {
+			l.logf("RUNNING")
+		}
+	case *Draining:
+		if e.Period > 0 {
+			l.logf("DRAINING\tfor %v", e.Period)
+		} else {
+			l.logf("DRAINING")
+		}
+	case *Drained:
+		if e.Err != nil {
+			l.logf("ERROR\t\tFailed to drain cleanly: %v", e.Err)
+		}
+	case *Stopped:
+		if e.Err != nil {
+			l.logf("ERROR\t\tFailed to stop cleanly: %v", e.Err)
+		}
+	case *RollingBack:
+		l.logf("ERROR\t\tStart failed, rolling back: %v", e.StartErr)
+	case *RolledBack:
+		if e.Err != nil {
+			l.logf("ERROR\t\tCouldn't rollback cleanly: %v", e.Err)
+		}
+	case *SignalReceived:
+		l.logf(strings.ToUpper(e.Signal.String()))
+	case *WorkerExited:
+		if e.Err != nil {
+			l.logf("ERROR\t\tWorker %q failed after %v: %v", e.WorkerName, e.Runtime, e.Err)
+		} else {
+			l.logf("EXIT\t\tWorker %q returned after %v", e.WorkerName, e.Runtime)
+		}
+	case *WorkerRestarting:
+		l.logf("RESTART\tWorker %q in %v (restart %d)", e.WorkerName, e.Delay, e.Restarts)
+	}
+}
+
+func fromModule(module string) string {
+	if module == "" {
+		return ""
+	}
+	return fmt.Sprintf(" from module %q", module)
+}
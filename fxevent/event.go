@@ -0,0 +1,343 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package fxevent defines the events emitted by Fx applications as they're
+// built, started, and stopped, along with consumers that write them out.
+//
+// Consumers implement the Logger interface and may be installed with the
+// fx.WithLogger option. The ConsoleLogger writes the same human-readable
+// lines Fx has always printed, and the JSONLogger writes one JSON object per
+// event for consumption by log pipelines.
+//
+// fxevent定义了Fx应用在构建、启动、停止过程中产生的结构化事件，以及输出这些事件的Logger实现(控制台格式与JSON格式)。
+package fxevent
+
+import (
+	"os"
+	"time"
+)
+
+// Event defines an event emitted by Fx.
+type Event interface {
+	event() // Only fxevent can implement this interface.
+}
+
+func (*LoggerInitialized) event() {}
+func (*Provided) event()          {}
+func (*Supplied) event()          {}
+func (*Decorated) event()         {}
+func (*Replaced) event()          {}
+func (*Invoking) event()          {}
+func (*Invoked) event()           {}
+func (*InvokeFailed) event()      {}
+func (*OnStartExecuting) event()  {}
+func (*OnStartExecuted) event()   {}
+func (*OnStopExecuting) event()   {}
+func (*OnStopExecuted) event()    {}
+func (*OnDrainExecuting) event()  {}
+func (*OnDrainExecuted) event()   {}
+func (*SlowHook) event()          {}
+func (*Started) event()           {}
+func (*Draining) event()          {}
+func (*Drained) event()           {}
+func (*Stopped) event()           {}
+func (*RollingBack) event()       {}
+func (*RolledBack) event()        {}
+func (*SignalReceived) event()    {}
+func (*WorkerExited) event()      {}
+func (*WorkerRestarting) event()  {}
+
+// LoggerInitialized is emitted once the Logger built by the constructor
+// passed to fx.WithLogger is ready, or if it couldn't be built.
+type LoggerInitialized struct {
+	// ConstructorName is the name of the constructor that built the Logger.
+	ConstructorName string
+
+	// Err is non-nil if the Logger couldn't be built. Events are then
+	// written to the default Logger instead.
+	Err error
+}
+
+// Provided is emitted when a constructor is provided to Fx.
+type Provided struct {
+	// ConstructorName is the name of the constructor that was provided.
+	ConstructorName string
+
+	// OutputTypeNames is a list of the types produced by the constructor.
+	OutputTypeNames []string
+
+	// ModuleName is the name of the fx.Module the constructor was provided
+	// in. It is empty for constructors passed directly to fx.New.
+	ModuleName string
+
+	// Private is true if the constructor was provided with fx.Private.
+	Private bool
+
+	// Err is non-nil if the constructor couldn't be provided, or if options
+	// applied before any constructor was provided failed.
+	Err error
+}
+
+// Supplied is emitted when a value is passed to fx.Supply.
+type Supplied struct {
+	// TypeName is the type of the value that was supplied.
+	TypeName string
+
+	// CallerName is the name of the function that called fx.Supply.
+	CallerName string
+
+	// ModuleName is the name of the fx.Module the value was supplied in.
+	ModuleName string
+
+	// Err is non-nil if the value couldn't be supplied.
+	Err error
+}
+
+// Decorated is emitted when a decorator is passed to fx.Decorate.
+type Decorated struct {
+	// DecoratorName is the name of the decorator function.
+	DecoratorName string
+
+	// OutputTypeNames is a list of the types decorated by the function.
+	OutputTypeNames []string
+
+	// ModuleName is the name of the fx.Module the decorator was declared in.
+	ModuleName string
+
+	// Err is non-nil if the decorator couldn't be registered.
+	Err error
+}
+
+// Replaced is emitted when a value is passed to fx.Replace.
+type Replaced struct {
+	// TypeName is the type of the value that replaced the provided one.
+	TypeName string
+
+	// CallerName is the name of the function that called fx.Replace.
+	CallerName string
+
+	// ModuleName is the name of the fx.Module the value was replaced in.
+	ModuleName string
+
+	// Err is non-nil if the value couldn't be replaced.
+	Err error
+}
+
+// Invoking is emitted before a function passed to fx.Invoke is called.
+type Invoking struct {
+	// FunctionName is the name of the function that will be invoked.
+	FunctionName string
+
+	// ModuleName is the name of the fx.Module the function was invoked in.
+	ModuleName string
+}
+
+// Invoked is emitted after a function passed to fx.Invoke returned
+// successfully.
+type Invoked struct {
+	// FunctionName is the name of the function that was invoked.
+	FunctionName string
+
+	// ModuleName is the name of the fx.Module the function was invoked in.
+	ModuleName string
+}
+
+// InvokeFailed is emitted when a function passed to fx.Invoke, or one of
+// the constructors it depends on, fails.
+type InvokeFailed struct {
+	// FunctionName is the name of the function that was invoked.
+	FunctionName string
+
+	// ModuleName is the name of the fx.Module the function was invoked in.
+	ModuleName string
+
+	// Err is the error that caused the failure.
+	Err error
+}
+
+// OnStartExecuting is emitted before an OnStart hook is executed.
+type OnStartExecuting struct {
+	// FunctionName is the name of the OnStart function.
+	FunctionName string
+
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+}
+
+// OnStartExecuted is emitted after an OnStart hook has been executed.
+type OnStartExecuted struct {
+	// FunctionName is the name of the OnStart function.
+	FunctionName string
+
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+
+	// Runtime is how long the hook took to run.
+	Runtime time.Duration
+
+	// Err is non-nil if the hook failed.
+	Err error
+}
+
+// OnStopExecuting is emitted before an OnStop hook is executed.
+type OnStopExecuting struct {
+	// FunctionName is the name of the OnStop function.
+	FunctionName string
+
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+}
+
+// OnStopExecuted is emitted after an OnStop hook has been executed.
+type OnStopExecuted struct {
+	// FunctionName is the name of the OnStop function.
+	FunctionName string
+
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+
+	// Runtime is how long the hook took to run.
+	Runtime time.Duration
+
+	// Err is non-nil if the hook failed.
+	Err error
+}
+
+// OnDrainExecuting is emitted before an OnDrain hook is executed.
+type OnDrainExecuting struct {
+	// FunctionName is the name of the OnDrain function.
+	FunctionName string
+
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+}
+
+// OnDrainExecuted is emitted after an OnDrain hook has been executed.
+type OnDrainExecuted struct {
+	// FunctionName is the name of the OnDrain function.
+	FunctionName string
+
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+
+	// Runtime is how long the hook took to run.
+	Runtime time.Duration
+
+	// Err is non-nil if the hook failed.
+	Err error
+}
+
+// SlowHook is emitted after an OnStart, OnDrain or OnStop hook ran for
+// longer than the threshold set with fx.SlowHookThreshold.
+type SlowHook struct {
+	// Hook is "OnStart", "OnDrain" or "OnStop".
+	Hook string
+
+	// FunctionName is the name of the hook function.
+	FunctionName string
+
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+
+	// Runtime is how long the hook took to run.
+	Runtime time.Duration
+
+	// Threshold is the runtime above which hooks are reported as slow.
+	Threshold time.Duration
+}
+
+// Started is emitted when an application is started, successfully or not.
+type Started struct {
+	// Err is non-nil if the application failed to start.
+	Err error
+}
+
+// Draining is emitted when an application that is about to stop begins
+// draining. It is only emitted if fx.DrainPeriod was set or some hook has
+// an OnDrain function.
+type Draining struct {
+	// Period is how long the application waits before running the OnDrain
+	// hooks, as set by fx.DrainPeriod.
+	Period time.Duration
+}
+
+// Drained is emitted when an application is done draining, successfully or
+// not.
+type Drained struct {
+	// Runtime is how long draining took.
+	Runtime time.Duration
+
+	// Err is non-nil if any OnDrain hook failed, or if draining timed out.
+	Err error
+}
+
+// Stopped is emitted when an application is stopped, successfully or not.
+type Stopped struct {
+	// Err is non-nil if any OnStop hook failed.
+	Err error
+}
+
+// RollingBack is emitted when an application failed to start and Fx begins
+// stopping the hooks that already started.
+type RollingBack struct {
+	// StartErr is the error that caused the rollback.
+	StartErr error
+}
+
+// RolledBack is emitted after the hooks started before a failure have been
+// stopped.
+type RolledBack struct {
+	// Err is non-nil if any of those hooks failed to stop.
+	Err error
+}
+
+// SignalReceived is emitted when a running application receives a signal
+// that ends it.
+type SignalReceived struct {
+	// Signal is the signal that was received.
+	Signal os.Signal
+}
+
+// WorkerExited is emitted when a task run by fx.Runner returns while the
+// application is still running.
+type WorkerExited struct {
+	// WorkerName is the name the worker was given.
+	WorkerName string
+
+	// Runtime is how long the worker ran before returning.
+	Runtime time.Duration
+
+	// Err is the error returned by the worker, if any.
+	Err error
+}
+
+// WorkerRestarting is emitted when a worker that exited is about to be
+// restarted, according to its fx.RestartPolicy.
+type WorkerRestarting struct {
+	// WorkerName is the name the worker was given.
+	WorkerName string
+
+	// Restarts is the number of times the worker was restarted within its
+	// restart window, including this one.
+	Restarts int
+
+	// Delay is how long Fx waits before restarting the worker.
+	Delay time.Duration
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxevent
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONLogger writes each event to a Writer as a single line of JSON. Every
+// object has an "event" key holding the name of the event type, along with
+// the event's fields. For example,
+//
+//  {"caller":"main.NewServer","event":"OnStartExecuted","function":"main.NewServer.func1()","runtime":"1.2ms"}
+//
+// Errors are written as their messages and durations as strings.
+type JSONLogger struct {
+	W io.Writer
+}
+
+var _ Logger = (*JSONLogger)(nil)
+
+// LogEvent writes the event as a line of JSON.
+func (l *JSONLogger) LogEvent(event Event) {
+	fields := jsonFields(event)
+	if fields == nil {
+		return
+	}
+	// Encode appends a newline, giving us one object per line.
+	json.NewEncoder(l.W).Encode(fields)
+}
+
+type fields map[string]interface{}
+
+// addErr adds the "error" key to the fields if err isn't nil.
+func (f fields) addErr(err error) fields {
+	if err != nil {
+		f["error"] = err.Error()
+	}
+	return f
+}
+
+// addString adds the key to the fields if the value isn't empty.
+func (f fields) addString(key, value string) fields {
+	if value != "" {
+		f[key] = value
+	}
+	return f
+}
+
+func jsonFields(event Event) fields {
+	switch e := event.(type) {
+	case *LoggerInitialized:
+		return fields{"event": "LoggerInitialized"}.
+			addString("constructor", e.ConstructorName).
+			addErr(e.Err)
+	case *Provided:
+		f := fields{"event": "Provided"}.
+			addString("constructor", e.ConstructorName).
+			addString("module", e.ModuleName).
+			addErr(e.Err)
+		if len(e.OutputTypeNames) > 0 {
+			f["types"] = e.OutputTypeNames
+		}
+		if e.Private {
+			f["private"] = true
+		}
+		return f
+	case *Supplied:
+		return fields{"event": "Supplied"}.
+			addString("type", e.TypeName).
+			addString("caller", e.CallerName).
+			addString("module", e.ModuleName).
+			addErr(e.Err)
+	case *Decorated:
+		f := fields{"event": "Decorated"}.
+			addString("decorator", e.DecoratorName).
+			addString("module", e.ModuleName).
+			addErr(e.Err)
+		if len(e.OutputTypeNames) > 0 {
+			f["types"] = e.OutputTypeNames
+		}
+		return f
+	case *Replaced:
+		return fields{"event": "Replaced"}.
+			addString("type", e.TypeName).
+			addString("caller", e.CallerName).
+			addString("module", e.ModuleName).
+			addErr(e.Err)
+	case *Invoking:
+		return fields{"event": "Invoking"}.
+			addString("function", e.FunctionName).
+			addString("module", e.ModuleName)
+	case *Invoked:
+		return fields{"event": "Invoked"}.
+			addString("function", e.FunctionName).
+			addString("module", e.ModuleName)
+	case *InvokeFailed:
+		return fields{"event": "InvokeFailed"}.
+			addString("function", e.FunctionName).
+			addString("module", e.ModuleName).
+			addErr(e.Err)
+	case *OnStartExecuting:
+		return fields{"event": "OnStartExecuting"}.
+			addString("function", e.FunctionName).
+			addString("caller", e.CallerName)
+	case *OnStartExecuted:
+		return fields{"event": "OnStartExecuted", "runtime": e.Runtime.String()}.
+			addString("function", e.FunctionName).
+			addString("caller", e.CallerName).
+			addErr(e.Err)
+	case *OnStopExecuting:
+		return fields{"event": "OnStopExecuting"}.
+			addString("function", e.FunctionName).
+			addString("caller", e.CallerName)
+	case *OnStopExecuted:
+		return fields{"event": "OnStopExecuted", "runtime": e.Runtime.String()}.
+			addString("function", e.FunctionName).
+			addString("caller", e.CallerName).
+			addErr(e.Err)
+	case *OnDrainExecuting:
+		return fields{"event": "OnDrainExecuting"}.
+			addString("function", e.FunctionName).
+			addString("caller", e.CallerName)
+	case *OnDrainExecuted:
+		return fields{"event": "OnDrainExecuted", "runtime": e.Runtime.String()}.
+			addString("function", e.FunctionName).
+			addString("caller", e.CallerName).
+			addErr(e.Err)
+	case *SlowHook:
+		return fields{
+			"event":     "SlowHook",
+			"runtime":   e.Runtime.String(),
+			"threshold": e.Threshold.String(),
+		}.
+			addString("hook", e.Hook).
+			addString("function", e.FunctionName).
+			addString("caller", e.CallerName)
+	case *Started:
+		return fields{"event": "Started"}.addErr(e.Err)
+	case *Draining:
+		return fields{"event": "Draining", "period": e.Period.String()}
+	case *Drained:
+		return fields{"event": "Drained", "runtime": e.Runtime.String()}.addErr(e.Err)
+	case *Stopped:
+		return fields{"event": "Stopped"}.addErr(e.Err)
+	case *RollingBack:
+		return fields{"event": "RollingBack"}.addErr(e.StartErr)
+	case *RolledBack:
+		return fields{"event": "RolledBack"}.addErr(e.Err)
+	case *SignalReceived:
+		return fields{"event": "SignalReceived", "signal": e.Signal.String()}
+	case *WorkerExited:
+		return fields{"event": "WorkerExited", "runtime": e.Runtime.String()}.
+			addString("worker", e.WorkerName).
+			addErr(e.Err)
+	case *WorkerRestarting:
+		return fields{
+			"event":    "WorkerRestarting",
+			"restarts": e.Restarts,
+			"delay":    e.Delay.String(),
+		}.addString("worker", e.WorkerName)
+	}
+	return nil
+}
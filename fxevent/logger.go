@@ -18,9 +18,18 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
-package sample
+package fxevent
 
-// New is a test constructor for a string
-func New() (string, error) {
-	return "Hi", nil
+// Logger consumes the events emitted by Fx.
+type Logger interface {
+	// LogEvent is called when an event happens. It must not retain the
+	// event after it returns.
+	LogEvent(Event)
 }
+
+// NopLogger is a Logger that discards all events.
+var NopLogger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) LogEvent(Event) {}
@@ -0,0 +1,259 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fxevent
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsoleLogger(t *testing.T) {
+	tests := []struct {
+		desc string
+		give Event
+		want string
+	}{
+		{
+			desc: "Provided",
+			give: &Provided{
+				ConstructorName: "bytes.NewBuffer()",
+				OutputTypeNames: []string{"*bytes.Buffer"},
+			},
+			want: "[Fx] PROVIDE\t*bytes.Buffer <= bytes.NewBuffer()\n",
+		},
+		{
+			desc: "ProvidedInModule",
+			give: &Provided{
+				ConstructorName: "bytes.NewBuffer()",
+				OutputTypeNames: []string{"*bytes.Buffer"},
+				ModuleName:      "buffers",
+			},
+			want: "[Fx] PROVIDE\t*bytes.Buffer <= bytes.NewBuffer() from module \"buffers\"\n",
+		},
+		{
+			desc: "ProvidedError",
+			give: &Provided{Err: errors.New("great sadness")},
+			want: "[Fx] Error after options were applied: great sadness\n",
+		},
+		{
+			desc: "Supplied",
+			give: &Supplied{TypeName: "*bytes.Buffer", CallerName: "main.main"},
+			want: "[Fx] SUPPLY\t*bytes.Buffer <= main.main\n",
+		},
+		{
+			desc: "Decorated",
+			give: &Decorated{
+				DecoratorName:   "main.decorate()",
+				OutputTypeNames: []string{"*log.Logger"},
+			},
+			want: "[Fx] DECORATE\t*log.Logger <= main.decorate()\n",
+		},
+		{
+			desc: "Replaced",
+			give: &Replaced{TypeName: "*log.Logger", CallerName: "main.main"},
+			want: "[Fx] REPLACE\t*log.Logger <= main.main\n",
+		},
+		{
+			desc: "Invoking",
+			give: &Invoking{FunctionName: "main.run()"},
+			want: "[Fx] INVOKE\t\tmain.run()\n",
+		},
+		{
+			desc: "Invoked",
+			give: &Invoked{FunctionName: "main.run()"},
+			want: "",
+		},
+		{
+			desc: "InvokeFailed",
+			give: &InvokeFailed{FunctionName: "main.run()", Err: errors.New("great sadness")},
+			want: "[Fx] Error during \"main.run()\" invoke: great sadness\n",
+		},
+		{
+			desc: "OnStartExecuting",
+			give: &OnStartExecuting{FunctionName: "main.run.func1()", CallerName: "main.run"},
+			want: "[Fx] START\t\tmain.run()\n",
+		},
+		{
+			desc: "OnStopExecuting",
+			give: &OnStopExecuting{FunctionName: "main.run.func2()", CallerName: "main.run"},
+			want: "[Fx] STOP\t\tmain.run()\n",
+		},
+		{
+			desc: "OnDrainExecuting",
+			give: &OnDrainExecuting{FunctionName: "main.run.func3()", CallerName: "main.run"},
+			want: "[Fx] DRAIN\t\tmain.run()\n",
+		},
+		{
+			desc: "SlowHook",
+			give: &SlowHook{
+				Hook:       "OnStart",
+				CallerName: "main.run",
+				Runtime:    2 * time.Second,
+				Threshold:  time.Second,
+			},
+			want: "[Fx] WARN\t\tOnStart hook added by main.run() took 2s, longer than 1s\n",
+		},
+		{
+			desc: "Started",
+			give: &Started{},
+			want: "[Fx] RUNNING\n",
+		},
+		{
+			desc: "StartFailed",
+			give: &Started{Err: errors.New("great sadness")},
+			want: "[Fx] ERROR\t\tFailed to start: great sadness\n",
+		},
+		{
+			desc: "Draining",
+			give: &Draining{Period: 5 * time.Second},
+			want: "[Fx] DRAINING\tfor 5s\n",
+		},
+		{
+			desc: "DrainingWithoutPeriod",
+			give: &Draining{},
+			want: "[Fx] DRAINING\n",
+		},
+		{
+			desc: "DrainFailed",
+			give: &Drained{Err: errors.New("great sadness")},
+			want: "[Fx] ERROR\t\tFailed to drain cleanly: great sadness\n",
+		},
+		{
+			desc: "Stopped",
+			give: &Stopped{},
+			want: "",
+		},
+		{
+			desc: "RollingBack",
+			give: &RollingBack{StartErr: errors.New("great sadness")},
+			want: "[Fx] ERROR\t\tStart failed, rolling back: great sadness\n",
+		},
+		{
+			desc: "RolledBackError",
+			give: &RolledBack{Err: errors.New("great sadness")},
+			want: "[Fx] ERROR\t\tCouldn't rollback cleanly: great sadness\n",
+		},
+		{
+			desc: "SignalReceived",
+			give: &SignalReceived{Signal: os.Interrupt},
+			want: "[Fx] INTERRUPT\n",
+		},
+		{
+			desc: "WorkerExited",
+			give: &WorkerExited{WorkerName: "consumer", Runtime: time.Second},
+			want: "[Fx] EXIT\t\tWorker \"consumer\" returned after 1s\n",
+		},
+		{
+			desc: "WorkerFailed",
+			give: &WorkerExited{WorkerName: "consumer", Runtime: time.Second, Err: errors.New("great sadness")},
+			want: "[Fx] ERROR\t\tWorker \"consumer\" failed after 1s: great sadness\n",
+		},
+		{
+			desc: "WorkerRestarting",
+			give: &WorkerRestarting{WorkerName: "consumer", Restarts: 2, Delay: 200 * time.Millisecond},
+			want: "[Fx] RESTART\tWorker \"consumer\" in 200ms (restart 2)\n",
+		},
+		{
+			desc: "LoggerInitialized",
+			give: &LoggerInitialized{ConstructorName: "main.newLogger()"},
+			want: "[Fx] LOGGER\tInitialized custom logger from main.newLogger()\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			(&ConsoleLogger{W: &buf}).LogEvent(tt.give)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	tests := []struct {
+		desc string
+		give Event
+		want string
+	}{
+		{
+			desc: "Provided",
+			give: &Provided{
+				ConstructorName: "bytes.NewBuffer()",
+				OutputTypeNames: []string{"*bytes.Buffer"},
+				ModuleName:      "buffers",
+				Private:         true,
+			},
+			want: `{"constructor":"bytes.NewBuffer()","event":"Provided","module":"buffers","private":true,"types":["*bytes.Buffer"]}`,
+		},
+		{
+			desc: "InvokeFailed",
+			give: &InvokeFailed{FunctionName: "main.run()", Err: errors.New("great sadness")},
+			want: `{"error":"great sadness","event":"InvokeFailed","function":"main.run()"}`,
+		},
+		{
+			desc: "OnStartExecuted",
+			give: &OnStartExecuted{
+				FunctionName: "main.run.func1()",
+				CallerName:   "main.run",
+				Runtime:      1500 * time.Microsecond,
+			},
+			want: `{"caller":"main.run","event":"OnStartExecuted","function":"main.run.func1()","runtime":"1.5ms"}`,
+		},
+		{
+			desc: "Started",
+			give: &Started{},
+			want: `{"event":"Started"}`,
+		},
+		{
+			desc: "Drained",
+			give: &Drained{Runtime: 2 * time.Second},
+			want: `{"event":"Drained","runtime":"2s"}`,
+		},
+		{
+			desc: "SignalReceived",
+			give: &SignalReceived{Signal: os.Interrupt},
+			want: `{"event":"SignalReceived","signal":"interrupt"}`,
+		},
+		{
+			desc: "WorkerRestarting",
+			give: &WorkerRestarting{WorkerName: "consumer", Restarts: 2, Delay: 200 * time.Millisecond},
+			want: `{"delay":"200ms","event":"WorkerRestarting","restarts":2,"worker":"consumer"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			(&JSONLogger{W: &buf}).LogEvent(tt.give)
+			assert.Equal(t, tt.want, strings.TrimSuffix(buf.String(), "\n"))
+		})
+	}
+}
+
+func TestNopLogger(t *testing.T) {
+	NopLogger.LogEvent(&Started{})
+}
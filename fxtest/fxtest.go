@@ -22,6 +22,7 @@ package fxtest
 
 import (
 	"context"
+	"fmt"
 
 	"go.uber.org/fx"
 	"fx-master/internal/lifecycle"
@@ -127,6 +128,10 @@ func (l *Lifecycle) RequireStart() *Lifecycle {
 // returned.
 func (l *Lifecycle) Stop(ctx context.Context) error { return l.lc.Stop(ctx) }
 
+// Drain calls the OnDrain hooks whose OnStart counterpart was called,
+// running in reverse order.
+func (l *Lifecycle) Drain(ctx context.Context) error { return l.lc.Drain(ctx) }
+
 // RequireStop calls Stop with context.Background(), failing the test if an error
 // is encountered.
 func (l *Lifecycle) RequireStop() {
@@ -139,10 +144,37 @@ func (l *Lifecycle) RequireStop() {
 	}
 }
 
-// Append registers a new Hook.
+// Append registers a new Hook. Lifecycle doesn't support stages, so the
+// test fails if the hook has a Stage other than fx.DefaultStage.
 func (l *Lifecycle) Append(h fx.Hook) {
-	l.lc.Append(lifecycle.Hook{
-		OnStart: h.OnStart,
-		OnStop:  h.OnStop,
-	})
+	hook, err := l.hook(h)
+	if err != nil {
+		l.t.Errorf("can't append hook: %v", err)
+		l.t.FailNow()
+		return
+	}
+	l.lc.Append(hook)
+}
+
+// AppendStarted registers a new Hook, calling its OnStart right away if the
+// lifecycle has already started. See fx.AppendStarted.
+func (l *Lifecycle) AppendStarted(ctx context.Context, h fx.Hook) error {
+	hook, err := l.hook(h)
+	if err != nil {
+		return err
+	}
+	return l.lc.AppendStarted(ctx, hook)
+}
+
+func (l *Lifecycle) hook(h fx.Hook) (lifecycle.Hook, error) {
+	if h.Stage != "" && h.Stage != fx.DefaultStage {
+		return lifecycle.Hook{}, fmt.Errorf("fxtest.Lifecycle doesn't support lifecycle stages: hook has stage %q", h.Stage)
+	}
+	return lifecycle.Hook{
+		OnStart:        h.OnStart,
+		OnStop:         h.OnStop,
+		OnDrain:        h.OnDrain,
+		OnStartTimeout: h.OnStartTimeout,
+		OnStopTimeout:  h.OnStopTimeout,
+	}, nil
 }
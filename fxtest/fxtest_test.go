@@ -158,6 +158,20 @@ func TestLifecycle(t *testing.T) {
 		assert.Equal(t, 1, spy.failures, "Expected lifecycle stop to fail.")
 	})
 
+	t.Run("Stage", func(t *testing.T) {
+		spy := newTB()
+		lc := NewLifecycle(spy)
+		lc.Append(fx.Hook{Stage: fx.DefaultStage})
+		assert.Zero(t, spy.failures, "Expected the default stage to be accepted.")
+
+		lc.Append(fx.Hook{Stage: "nonexistent"})
+		assert.Equal(t, 1, spy.failures, "Expected stages to be rejected.")
+		assert.Contains(t, spy.errors.String(), `doesn't support lifecycle stages: hook has stage "nonexistent"`)
+
+		err := lc.AppendStarted(context.Background(), fx.Hook{Stage: "nonexistent"})
+		assert.Error(t, err)
+	})
+
 	t.Run("RequireLeakDetection", func(t *testing.T) {
 		spy := newTB()
 		lc := NewLifecycle(spy)
@@ -0,0 +1,443 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"fx-master/internal/fxreflect"
+	"go.uber.org/dig"
+)
+
+// Graph is a structured description of an application's dependency graph:
+// every constructor, decorator and invoked function Fx knows about, and the
+// values that flow between them. Use App.Graph to get one.
+//
+// Graphs can be rendered as Graphviz DOT, as Mermaid flowcharts, or as JSON.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a function in a Graph.
+type GraphNode struct {
+	// ID identifies the node within the graph.
+	ID string `json:"id"`
+
+	// Kind is "constructor", "decorator", "invoke", or "missing" for values
+	// that some function needs but nothing provides.
+	Kind string `json:"kind"`
+
+	// Name is the name of the function, or the missing value.
+	Name string `json:"name"`
+
+	// Module is the name of the fx.Module the function was passed to. It's
+	// empty for functions passed to fx.New directly.
+	Module string `json:"module,omitempty"`
+
+	// File and Line locate the function's definition, if known.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+
+	// Outputs are the values produced by the function, formatted like
+	// "*log.Logger", `*sql.DB[name="ro"]` or `http.Handler[group="routes"]`.
+	Outputs []string `json:"outputs,omitempty"`
+
+	// Instantiated reports whether the function has run successfully.
+	// Constructors only run when something needs their results.
+	Instantiated bool `json:"instantiated"`
+
+	// Failed reports whether the function ran and failed, and Error holds
+	// its error.
+	Failed bool   `json:"failed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GraphEdge is a value passed from one node of a Graph to another.
+type GraphEdge struct {
+	// From is the ID of the node that produces the value, and To the ID of
+	// the node with the parameter that receives it.
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Type is the value's type. Name and Group are set for named values and
+	// value groups.
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Group string `json:"group,omitempty"`
+
+	// Optional reports whether the parameter is optional.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Graph returns the application's dependency graph as it stands: which
+// functions have been called depends on the invocations made so far.
+//
+// 返回结构化的依赖关系图，可输出为DOT、Mermaid或JSON
+func (app *App) Graph() *Graph {
+	g := &Graph{}
+	ids := make(map[*depNode]string, len(app.nodes))
+	for i, n := range app.nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+		node := GraphNode{
+			ID:           ids[n],
+			Kind:         n.kind,
+			Name:         n.name,
+			Module:       n.module,
+			File:         n.file,
+			Line:         n.line,
+			Instantiated: n.called && n.err == nil,
+			Failed:       n.err != nil,
+		}
+		if n.err != nil {
+			node.Error = n.err.Error()
+		}
+		for _, k := range n.outputs {
+			node.Outputs = append(node.Outputs, k.String())
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	producers := producersByKey(app.nodes)
+	missing := make(map[depKey]string)
+	for _, n := range app.nodes {
+		for _, param := range n.inputs {
+			edge := GraphEdge{
+				To:       ids[n],
+				Type:     param.key.t.String(),
+				Name:     param.key.name,
+				Group:    param.key.group,
+				Optional: param.optional,
+			}
+
+			var found bool
+			// Values provided privately in other modules can't be
+			// consumed, so they're drawn as missing.
+			for _, p := range producers[param.key] {
+				if p == n || !p.visibleTo(n.module) {
+					continue
+				}
+				found = true
+				edge.From = ids[p]
+				g.Edges = append(g.Edges, edge)
+			}
+
+			// Groups may be empty and optional values absent, but
+			// anything else must come from somewhere.
+			if found || param.optional || param.key.group != "" {
+				continue
+			}
+			id, ok := missing[param.key]
+			if !ok {
+				id = fmt.Sprintf("m%d", len(missing))
+				missing[param.key] = id
+				g.Nodes = append(g.Nodes, GraphNode{
+					ID:   id,
+					Kind: "missing",
+					Name: param.key.String(),
+				})
+			}
+			edge.From = id
+			g.Edges = append(g.Edges, edge)
+		}
+	}
+	return g
+}
+
+// JSON returns the graph as indented JSON.
+func (g *Graph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// DOT returns the graph in the Graphviz DOT language. Values flow along the
+// arrows. Failed nodes are red, nodes that haven't run are grey, and missing
+// values are dashed.
+func (g *Graph) DOT() string {
+	var b bytes.Buffer
+	b.WriteString("digraph {\n\trankdir=LR;\n")
+	for _, n := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%q", nodeLabel(n, `\n`)), "shape=box"}
+		switch {
+		case n.Kind == "missing":
+			attrs = append(attrs, "style=dashed", "color=red")
+		case n.Failed:
+			attrs = append(attrs, "color=red", "fontcolor=red")
+		case !n.Instantiated:
+			attrs = append(attrs, "color=gray", "fontcolor=gray")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", n.ID, strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{fmt.Sprintf("label=%q", edgeLabel(e))}
+		if e.Optional {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", e.From, e.To, strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid returns the graph as a Mermaid flowchart, styled like DOT.
+// Optional parameters are drawn with dotted arrows.
+func (g *Graph) Mermaid() string {
+	var b bytes.Buffer
+	b.WriteString("flowchart LR\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "\t%s[\"%s\"]\n", n.ID, mermaidEscape(nodeLabel(n, "<br/>")))
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Optional {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "\t%s %s|\"%s\"| %s\n", e.From, arrow, mermaidEscape(edgeLabel(e)), e.To)
+	}
+
+	b.WriteString("\tclassDef failed stroke:red,color:red\n")
+	b.WriteString("\tclassDef pending stroke:gray,color:gray\n")
+	b.WriteString("\tclassDef missing stroke:red,stroke-dasharray:5\n")
+	for _, n := range g.Nodes {
+		switch {
+		case n.Kind == "missing":
+			fmt.Fprintf(&b, "\tclass %s missing\n", n.ID)
+		case n.Failed:
+			fmt.Fprintf(&b, "\tclass %s failed\n", n.ID)
+		case !n.Instantiated:
+			fmt.Fprintf(&b, "\tclass %s pending\n", n.ID)
+		}
+	}
+	return b.String()
+}
+
+func nodeLabel(n GraphNode, sep string) string {
+	lines := []string{n.Name}
+	if n.Module != "" {
+		lines = append(lines, fmt.Sprintf("module %q", n.Module))
+	}
+	return strings.Join(append(lines, n.Outputs...), sep)
+}
+
+func edgeLabel(e GraphEdge) string {
+	label := depKey{name: e.Name, group: e.Group}.format(e.Type)
+	if e.Optional {
+		label += " (optional)"
+	}
+	return label
+}
+
+func mermaidEscape(s string) string {
+	return strings.Replace(s, `"`, "#quot;", -1)
+}
+
+// depKey identifies a value in the container.
+type depKey struct {
+	t     reflect.Type
+	name  string
+	group string
+}
+
+func (k depKey) String() string {
+	return k.format(k.t.String())
+}
+
+// format formats the key like dig does in its errors.
+func (k depKey) format(typ string) string {
+	switch {
+	case k.name != "":
+		return fmt.Sprintf("%v[name=%q]", typ, k.name)
+	case k.group != "":
+		return fmt.Sprintf("%v[group=%q]", typ, k.group)
+	}
+	return typ
+}
+
+// depParam is a value consumed by a depNode.
+type depParam struct {
+	key      depKey
+	optional bool
+}
+
+// depNode is a constructor, decorator or invoked function, along with the
+// values it consumes and produces, and what happened when it was called.
+type depNode struct {
+	kind   string
+	name   string
+	module string
+	file   string
+	line   int
+
+	inputs  []depParam
+	outputs []depKey
+	private bool // provided with Private, see visibleTo
+
+	called bool
+	err    error
+}
+
+// newDepNode describes the given function. For annotated constructors, the
+// name or group applies to all of the function's results, as it does in dig.
+func newDepNode(kind string, m *module, fn interface{}) *depNode {
+	var name, group string
+	if a, ok := fn.(Annotated); ok {
+		name, group, fn = a.Name, a.Group, a.Target
+	}
+
+	// Annotated functions are described by their wrapper's parameters and
+	// results, but named after the function itself.
+	target := fn
+	if a, ok := fn.(annotated); ok {
+		target = a.Target
+		fn, _ = a.build()
+	}
+
+	n := &depNode{kind: kind, name: fxreflect.FuncName(target), module: m.path()}
+	if tt := reflect.TypeOf(target); tt != nil && tt.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(reflect.ValueOf(target).Pointer()); f != nil {
+			n.file, n.line = f.FileLine(f.Entry())
+		}
+	}
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return n
+	}
+	for i := 0; i < ft.NumIn(); i++ {
+		n.inputs = appendParamKeys(n.inputs, ft.In(i), false)
+	}
+	for i := 0; i < ft.NumOut(); i++ {
+		n.outputs = appendOutputKeys(n.outputs, ft.Out(i), name, group)
+	}
+	return n
+}
+
+// track adds a node to the application's dependency graph.
+func (app *App) track(n *depNode) {
+	app.nodes = append(app.nodes, n)
+}
+
+// nodesOfKind returns the nodes of the dependency graph of the given kind.
+func (app *App) nodesOfKind(kind string) []*depNode {
+	var nodes []*depNode
+	for _, n := range app.nodes {
+		if n.kind == kind {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// visibleTo reports whether the values produced by the node can be consumed
+// from the module with the given path. Private values are only visible
+// inside their module and the modules nested in it.
+func (n *depNode) visibleTo(module string) bool {
+	return !n.private || n.module == "" || module == n.module || strings.HasPrefix(module, n.module+".")
+}
+
+// ran records the outcome of calling a node's function.
+func (n *depNode) ran(err error) {
+	n.called = true
+	n.err = err
+}
+
+func producersByKey(nodes []*depNode) map[depKey][]*depNode {
+	producers := make(map[depKey][]*depNode)
+	for _, n := range nodes {
+		for _, k := range n.outputs {
+			producers[k] = append(producers[k], n)
+		}
+	}
+	return producers
+}
+
+func appendParamKeys(params []depParam, t reflect.Type, optional bool) []depParam {
+	if t.Kind() != reflect.Struct || !dig.IsIn(t) {
+		return append(params, depParam{key: depKey{t: t}, optional: optional})
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case f.Type.Kind() == reflect.Struct && dig.IsIn(f.Type):
+			params = appendParamKeys(params, f.Type, optional)
+		case f.PkgPath != "":
+			// dig doesn't fill in unexported fields.
+		default:
+			// Group fields receive a slice of the group's values. Soft
+			// groups don't call constructors, so they're drawn as optional.
+			p := depParam{
+				key:      depKey{t: f.Type, name: f.Tag.Get("name")},
+				optional: optional || f.Tag.Get("optional") == "true",
+			}
+			if groups, opts := groupNames(f.Tag.Get("group")); len(groups) > 0 {
+				p.key = depKey{t: f.Type.Elem(), group: groups[0]}
+				p.optional = p.optional || hasOption(opts, "soft")
+			}
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+func appendOutputKeys(keys []depKey, t reflect.Type, name, group string) []depKey {
+	if t == _typeOfError {
+		return keys
+	}
+	if t.Kind() != reflect.Struct || !dig.IsOut(t) {
+		return appendValueKeys(keys, t, name, group)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case f.Type.Kind() == reflect.Struct && dig.IsOut(f.Type):
+			keys = appendOutputKeys(keys, f.Type, "", "")
+		case f.PkgPath != "":
+		default:
+			keys = appendValueKeys(keys, f.Type, f.Tag.Get("name"), f.Tag.Get("group"))
+		}
+	}
+	return keys
+}
+
+// appendValueKeys adds a key for a value's name, if any, and one for each of
+// its value groups. Flattened groups receive each element of the slice.
+func appendValueKeys(keys []depKey, t reflect.Type, name, groupTag string) []depKey {
+	groups, opts := groupNames(groupTag)
+	if name != "" || len(groups) == 0 {
+		keys = append(keys, depKey{t: t, name: name})
+	}
+
+	gt := t
+	for _, opt := range opts {
+		if opt == "flatten" && t.Kind() == reflect.Slice {
+			gt = t.Elem()
+		}
+	}
+	for _, g := range groups {
+		keys = append(keys, depKey{t: gt, group: g})
+	}
+	return keys
+}
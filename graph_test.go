@@ -0,0 +1,161 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func TestGraph(t *testing.T) {
+	type A struct{}
+	type B struct{}
+	type C struct{}
+
+	type params struct {
+		fx.In
+
+		A *A   `name:"primary"`
+		B *B   `optional:"true"`
+		C []*C `group:"cs"`
+	}
+
+	findNode := func(t *testing.T, g *fx.Graph, kind string) fx.GraphNode {
+		for _, n := range g.Nodes {
+			if n.Kind == kind {
+				return n
+			}
+		}
+		t.Fatalf("no %q node in graph", kind)
+		return fx.GraphNode{}
+	}
+
+	t.Run("NodesAndEdges", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("store",
+				fx.Provide(fx.Annotated{Name: "primary", Target: func() *A { return &A{} }}),
+			),
+			fx.Provide(fx.Annotated{Group: "cs", Target: func() *C { return &C{} }}),
+			fx.Invoke(func(params) {}),
+		)
+		require.NoError(t, app.Err())
+
+		g := app.Graph()
+		var a fx.GraphNode
+		for _, n := range g.Nodes {
+			if n.Module == "store" {
+				a = n
+			}
+		}
+		assert.Equal(t, "constructor", a.Kind)
+		assert.Equal(t, []string{`*fx_test.A[name="primary"]`}, a.Outputs)
+		assert.NotEmpty(t, a.File)
+		assert.NotZero(t, a.Line)
+		assert.True(t, a.Instantiated)
+		assert.False(t, a.Failed)
+
+		invoke := findNode(t, g, "invoke")
+		var edges []fx.GraphEdge
+		for _, e := range g.Edges {
+			if e.To == invoke.ID {
+				edges = append(edges, e)
+			}
+		}
+		assert.Contains(t, edges, fx.GraphEdge{From: a.ID, To: invoke.ID, Type: "*fx_test.A", Name: "primary"})
+		for _, e := range edges {
+			assert.NotEqual(t, "*fx_test.B", e.Type, "optional values that nothing provides have no edge")
+			if e.Type == "*fx_test.C" {
+				assert.Equal(t, "cs", e.Group)
+			}
+		}
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Provide(func() (*A, error) { return nil, errors.New("great sadness") }),
+			fx.Provide(func(*B) *C { return &C{} }),
+			fx.Invoke(func(*A) {}),
+		)
+		require.Error(t, app.Err())
+
+		g := app.Graph()
+		for _, n := range g.Nodes {
+			if n.Kind == "constructor" {
+				assert.False(t, n.Instantiated, "%v shouldn't have run successfully", n.Name)
+			}
+		}
+
+		a := findNode(t, g, "constructor")
+		assert.True(t, a.Failed)
+		assert.Contains(t, a.Error, "great sadness")
+		assert.True(t, findNode(t, g, "invoke").Failed)
+
+		missing := findNode(t, g, "missing")
+		assert.Equal(t, "*fx_test.B", missing.Name)
+	})
+
+	t.Run("PrivateValues", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("store", fx.Provide(fx.Private, func() *B { return &B{} })),
+			fx.Invoke(func(*B) {}),
+		)
+		require.Error(t, app.Err())
+
+		g := app.Graph()
+		b, invoke, missing := findNode(t, g, "constructor"), findNode(t, g, "invoke"), findNode(t, g, "missing")
+		assert.Equal(t, "*fx_test.B", missing.Name)
+		assert.Contains(t, g.Edges, fx.GraphEdge{From: missing.ID, To: invoke.ID, Type: "*fx_test.B"})
+		assert.NotContains(t, g.Edges, fx.GraphEdge{From: b.ID, To: invoke.ID, Type: "*fx_test.B"},
+			"values private to other modules can't be consumed")
+	})
+
+	t.Run("Render", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Provide(func() *A { return &A{} }),
+			fx.Invoke(func(*A) {}),
+		)
+		require.NoError(t, app.Err())
+		g := app.Graph()
+
+		dot := g.DOT()
+		assert.Contains(t, dot, "digraph {")
+		assert.Contains(t, dot, `label="*fx_test.A"`)
+
+		mermaid := g.Mermaid()
+		assert.Contains(t, mermaid, "flowchart LR")
+		assert.Contains(t, mermaid, `|"*fx_test.A"|`)
+
+		b, err := g.JSON()
+		require.NoError(t, err)
+		var decoded fx.Graph
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		assert.Equal(t, *g, decoded)
+	})
+}
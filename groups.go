@@ -0,0 +1,507 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/dig"
+)
+
+var (
+	_typeOfFxIn   = reflect.TypeOf(In{})
+	_typeOfDigIn  = reflect.TypeOf(dig.In{})
+	_typeOfFxOut  = reflect.TypeOf(Out{})
+	_typeOfDigOut = reflect.TypeOf(dig.Out{})
+
+	_typeOfGroupEntries = reflect.TypeOf([]*groupEntry(nil))
+)
+
+// groupNames splits a group tag into the names of its value groups and its
+// options, such as "flatten" or "soft".
+func groupNames(tag string) (groups []string, opts []string) {
+	for _, part := range strings.Split(tag, ",") {
+		switch part = strings.TrimSpace(part); part {
+		case "":
+		case "flatten", "soft", "ordered":
+			opts = append(opts, part)
+		default:
+			groups = append(groups, part)
+		}
+	}
+	return groups, opts
+}
+
+// orderedGroups tracks the value groups that some function consumes in
+// order. Each member of those groups is also sent to a hidden group, wrapped
+// in a groupEntry that says where it belongs.
+//
+// 有序group：成员同时以groupEntry的形式进入隐藏group，消费时按priority及Provide顺序排序
+type orderedGroups struct {
+	hidden map[depKey]string // hidden group for each ordered group
+	seq    int               // number of constructors provided so far
+}
+
+// groupEntry is a member of an ordered value group.
+type groupEntry struct {
+	value    reflect.Value
+	priority int
+	seq      int // position of the constructor in provide order
+	index    int // position within a flattened slice
+}
+
+// scan records the ordered value groups consumed by the given function.
+func (o *orderedGroups) scan(fn interface{}) {
+	switch a := fn.(type) {
+	case Annotated:
+		fn = a.Target
+	case annotated:
+		fn, _ = a.build()
+	}
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return
+	}
+	for i := 0; i < ft.NumIn(); i++ {
+		walkIn(ft.In(i), nil, func(f reflect.StructField, _ []int) {
+			groups, opts := groupNames(f.Tag.Get("group"))
+			if len(groups) == 0 || !hasOption(opts, "ordered") || f.Type.Kind() != reflect.Slice {
+				return
+			}
+			k := depKey{t: f.Type.Elem(), group: groups[0]}
+			if o.hidden == nil {
+				o.hidden = make(map[depKey]string)
+			}
+			if _, ok := o.hidden[k]; !ok {
+				o.hidden[k] = fmt.Sprintf("fx.ordered.%d", len(o.hidden))
+			}
+		})
+	}
+}
+
+// checkDecorator returns an error if the decorator decorates an ordered
+// value group. Ordered parameters read the group's hidden copy, so they'd
+// silently miss the decorated values.
+func (o *orderedGroups) checkDecorator(fn interface{}) error {
+	ft := reflect.TypeOf(fn)
+	if len(o.hidden) == 0 || ft == nil || ft.Kind() != reflect.Func {
+		return nil
+	}
+
+	var err error
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField() && err == nil; i++ {
+			f := t.Field(i)
+			switch {
+			case f.Anonymous && isMarker(f.Type):
+				continue
+			case f.Type.Kind() == reflect.Struct && dig.IsOut(f.Type):
+				walk(f.Type)
+				continue
+			}
+			groups, _ := groupNames(f.Tag.Get("group"))
+			if f.Type.Kind() != reflect.Slice {
+				continue
+			}
+			for _, g := range groups {
+				if _, ok := o.hidden[depKey{t: f.Type.Elem(), group: g}]; ok {
+					err = fmt.Errorf("cannot decorate value group %q of %v: the group is consumed with the \"ordered\" option", g, f.Type.Elem())
+					return
+				}
+			}
+		}
+	}
+	for i := 0; i < ft.NumOut() && err == nil; i++ {
+		if t := ft.Out(i); t.Kind() == reflect.Struct && dig.IsOut(t) {
+			walk(t)
+		}
+	}
+	return err
+}
+
+// walkIn calls f with every field of an fx.In struct, including those of
+// nested fx.In structs, along with its index path. It reports false, without
+// calling f, for types that aren't fx.In structs or that have unexported
+// fields.
+func walkIn(t reflect.Type, path []int, f func(reflect.StructField, []int)) bool {
+	if t.Kind() != reflect.Struct || !dig.IsIn(t) || hasUnexported(t, dig.IsIn) {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fpath := append(append([]int(nil), path...), i)
+		switch {
+		case field.Anonymous && isMarker(field.Type):
+		case field.Type.Kind() == reflect.Struct && dig.IsIn(field.Type):
+			walkIn(field.Type, fpath, f)
+		default:
+			f(field, fpath)
+		}
+	}
+	return true
+}
+
+// hasUnexported reports whether a parameter or result struct, or one nested
+// in it, has unexported fields. Fx leaves those for dig to report.
+func hasUnexported(t reflect.Type, nested func(interface{}) bool) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && isMarker(f.Type) {
+			continue
+		}
+		if f.PkgPath != "" {
+			return true
+		}
+		if f.Type.Kind() == reflect.Struct && nested(f.Type) && hasUnexported(f.Type, nested) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMarker reports whether t is embedded to mark parameter or result
+// structs.
+func isMarker(t reflect.Type) bool {
+	return t == _typeOfFxIn || t == _typeOfDigIn || t == _typeOfFxOut || t == _typeOfDigOut
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapResults makes a constructor's results acceptable to dig, and sends
+// members of ordered value groups to their hidden groups.
+//
+// Dig allows one name or group per fx.Out field, so fields that are named
+// and in value groups, or in several value groups, are split into one field
+// for each. Every name and group receives the same value. Constructors that
+// need none of this are returned as is.
+//
+// 将同时带有name与group(或多个group)的返回值拆分为多个字段，保证各处共享同一实例
+func (o *orderedGroups) wrapResults(fn interface{}) (interface{}, error) {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return fn, nil
+	}
+	o.seq++
+
+	var changed bool
+	results := make([]reflect.Type, ft.NumOut())
+	mappers := make([]func(reflect.Value) reflect.Value, ft.NumOut())
+	for i := range results {
+		results[i] = ft.Out(i)
+		t, m, err := o.splitOut(ft.Out(i), o.seq)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			results[i], mappers[i], changed = t, m, true
+		}
+	}
+	if !changed {
+		return fn, nil
+	}
+
+	params := make([]reflect.Type, ft.NumIn())
+	for i := range params {
+		params[i] = ft.In(i)
+	}
+	call := reflect.ValueOf(fn).Call
+	if ft.IsVariadic() {
+		call = reflect.ValueOf(fn).CallSlice
+	}
+	newFT := reflect.FuncOf(params, results, ft.IsVariadic())
+	return reflect.MakeFunc(newFT, func(args []reflect.Value) []reflect.Value {
+		results := call(args)
+		for i, m := range mappers {
+			if m != nil {
+				results[i] = m(results[i])
+			}
+		}
+		return results
+	}).Interface(), nil
+}
+
+// splitOut returns a flattened copy of an fx.Out struct type with one field
+// per name and value group, plus hidden fields for ordered groups, and a
+// function that fills it in from a value of the original type. The function
+// is nil if the type doesn't need changing.
+func (o *orderedGroups) splitOut(t reflect.Type, seq int) (reflect.Type, func(reflect.Value) reflect.Value, error) {
+	if t.Kind() != reflect.Struct || !dig.IsOut(t) || hasUnexported(t, dig.IsOut) {
+		return nil, nil, nil
+	}
+
+	// Each field of the new struct is filled in from the value at path,
+	// wrapped in groupEntries if entry is set.
+	type field struct {
+		path     []int
+		entry    bool
+		flatten  bool
+		priority int
+	}
+	structFields := []reflect.StructField{{
+		Name:      "Out",
+		Type:      _typeOfFxOut,
+		Anonymous: true,
+	}}
+	var fields []field
+	var changed bool
+	add := func(f field, typ reflect.Type, tag string) {
+		structFields = append(structFields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", len(fields)),
+			Type: typ,
+			Tag:  reflect.StructTag(tag),
+		})
+		fields = append(fields, f)
+	}
+
+	var walk func(t reflect.Type, path []int) error
+	walk = func(t reflect.Type, path []int) error {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fpath := append(append([]int(nil), path...), i)
+			switch {
+			case f.Anonymous && isMarker(f.Type):
+				continue
+			case f.Type.Kind() == reflect.Struct && dig.IsOut(f.Type):
+				if err := walk(f.Type, fpath); err != nil {
+					return err
+				}
+				continue
+			}
+
+			name := f.Tag.Get("name")
+			groups, opts := groupNames(f.Tag.Get("group"))
+			var priority int
+			if p, ok := f.Tag.Lookup("priority"); ok {
+				var err error
+				if priority, err = strconv.Atoi(p); err != nil {
+					return fmt.Errorf("invalid priority %q for field %v of %v: must be an integer", p, f.Name, t)
+				}
+			}
+
+			if len(groups) > 1 || (name != "" && len(groups) > 0) {
+				changed = true
+				if name != "" {
+					add(field{path: fpath}, f.Type, fmt.Sprintf("name:%q", name))
+				}
+				for _, g := range groups {
+					add(field{path: fpath}, f.Type, fmt.Sprintf("group:%q", strings.Join(append([]string{g}, opts...), ",")))
+				}
+			} else {
+				add(field{path: fpath}, f.Type, string(f.Tag))
+			}
+
+			flatten := hasOption(opts, "flatten") && f.Type.Kind() == reflect.Slice
+			member := f.Type
+			if flatten {
+				member = f.Type.Elem()
+			}
+			for _, g := range groups {
+				hidden, ok := o.hidden[depKey{t: member, group: g}]
+				if !ok {
+					continue
+				}
+				changed = true
+				add(field{path: fpath, entry: true, flatten: flatten, priority: priority},
+					_typeOfGroupEntries, fmt.Sprintf(`group:"%s,flatten"`, hidden))
+			}
+		}
+		return nil
+	}
+	if err := walk(t, nil); err != nil || !changed {
+		return nil, nil, err
+	}
+
+	split := reflect.StructOf(structFields)
+	return split, func(v reflect.Value) reflect.Value {
+		out := reflect.New(split).Elem()
+		for i, f := range fields {
+			value := v.FieldByIndex(f.path)
+			if !f.entry {
+				out.Field(i + 1).Set(value)
+				continue
+			}
+
+			var entries []*groupEntry
+			if !f.flatten {
+				value = reflect.Append(reflect.MakeSlice(reflect.SliceOf(value.Type()), 0, 1), value)
+			}
+			for j := 0; j < value.Len(); j++ {
+				entries = append(entries, &groupEntry{
+					value:    value.Index(j),
+					priority: f.priority,
+					seq:      seq,
+					index:    j,
+				})
+			}
+			out.Field(i + 1).Set(reflect.ValueOf(entries))
+		}
+		return out
+	}, nil
+}
+
+// wrapParams replaces the ordered value groups in a function's parameters
+// with their hidden groups, and sorts their members before calling it.
+// Functions without ordered groups are returned as is.
+func (o *orderedGroups) wrapParams(fn interface{}) interface{} {
+	ft := reflect.TypeOf(fn)
+	if len(o.hidden) == 0 || ft == nil || ft.Kind() != reflect.Func {
+		return fn
+	}
+
+	var changed bool
+	params := make([]reflect.Type, ft.NumIn())
+	mappers := make([]func(reflect.Value) reflect.Value, ft.NumIn())
+	for i := range params {
+		params[i] = ft.In(i)
+		if t, m := o.orderIn(ft.In(i)); m != nil {
+			params[i], mappers[i], changed = t, m, true
+		}
+	}
+	if !changed {
+		return fn
+	}
+
+	results := make([]reflect.Type, ft.NumOut())
+	for i := range results {
+		results[i] = ft.Out(i)
+	}
+	call := reflect.ValueOf(fn).Call
+	if ft.IsVariadic() {
+		call = reflect.ValueOf(fn).CallSlice
+	}
+	newFT := reflect.FuncOf(params, results, ft.IsVariadic())
+	return reflect.MakeFunc(newFT, func(args []reflect.Value) []reflect.Value {
+		for i, m := range mappers {
+			if m != nil {
+				args[i] = m(args[i])
+			}
+		}
+		return call(args)
+	}).Interface()
+}
+
+// explain rewrites an error that names hidden groups, as dig errors about
+// ordered parameters do, to name the ordered groups they stand for.
+func (o *orderedGroups) explain(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	for k, hidden := range o.hidden {
+		from := depKey{t: reflect.TypeOf(&groupEntry{}), group: hidden}.String()
+		if strings.Contains(msg, from) {
+			err = relocatedError{err: err, from: from, to: k.String()}
+		}
+	}
+	return err
+}
+
+// orderIn returns a flattened copy of an fx.In struct type whose ordered
+// value groups are replaced by their hidden groups, and a function that
+// builds a value of the original type from it. The function is nil if the
+// type has no ordered groups.
+func (o *orderedGroups) orderIn(t reflect.Type) (reflect.Type, func(reflect.Value) reflect.Value) {
+	type field struct {
+		path    []int
+		ordered bool
+	}
+	structFields := []reflect.StructField{{
+		Name:      "In",
+		Type:      _typeOfFxIn,
+		Anonymous: true,
+	}}
+	var fields []field
+	var changed bool
+
+	ok := walkIn(t, nil, func(f reflect.StructField, path []int) {
+		sf := reflect.StructField{
+			Name: fmt.Sprintf("Field%d", len(fields)),
+			Type: f.Type,
+			Tag:  f.Tag,
+		}
+		groups, opts := groupNames(f.Tag.Get("group"))
+		hidden, ordered := "", false
+		if len(groups) > 0 && hasOption(opts, "ordered") && f.Type.Kind() == reflect.Slice {
+			hidden, ordered = o.hidden[depKey{t: f.Type.Elem(), group: groups[0]}]
+		}
+		if ordered {
+			changed = true
+			tag := hidden
+			if hasOption(opts, "soft") {
+				tag += ",soft"
+			}
+			sf.Type = _typeOfGroupEntries
+			sf.Tag = reflect.StructTag(strings.Replace(string(f.Tag),
+				fmt.Sprintf("group:%q", f.Tag.Get("group")), fmt.Sprintf("group:%q", tag), 1))
+		}
+		structFields = append(structFields, sf)
+		fields = append(fields, field{path: path, ordered: ordered})
+	})
+	if !ok || !changed {
+		return nil, nil
+	}
+
+	ordered := reflect.StructOf(structFields)
+	return ordered, func(v reflect.Value) reflect.Value {
+		in := reflect.New(t).Elem()
+		for i, f := range fields {
+			value := v.Field(i + 1)
+			if f.ordered {
+				value = sortEntries(value.Interface().([]*groupEntry), in.FieldByIndex(f.path).Type())
+			}
+			in.FieldByIndex(f.path).Set(value)
+		}
+		return in
+	}
+}
+
+// sortEntries returns the members of an ordered value group as a slice of
+// the given type, lowest priority first, then in provide order.
+func sortEntries(entries []*groupEntry, t reflect.Type) reflect.Value {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch {
+		case a.priority != b.priority:
+			return a.priority < b.priority
+		case a.seq != b.seq:
+			return a.seq < b.seq
+		}
+		return a.index < b.index
+	})
+
+	values := reflect.MakeSlice(t, len(entries), len(entries))
+	for i, e := range entries {
+		values.Index(i).Set(e.value)
+	}
+	return values
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestSoftGroups(t *testing.T) {
+	type A struct{}
+
+	var built []string
+	newMember := func(name string) func() string {
+		return func() string {
+			built = append(built, name)
+			return name
+		}
+	}
+
+	type out struct {
+		fx.Out
+
+		A    *A
+		Name string `group:"names"`
+	}
+
+	type params struct {
+		fx.In
+
+		Names []string `group:"names,soft"`
+	}
+
+	var got []string
+	app := fxtest.New(t,
+		fx.Provide(
+			fx.Annotated{Group: "names", Target: newMember("unused")},
+			func() out {
+				built = append(built, "used")
+				return out{A: &A{}, Name: "used"}
+			},
+		),
+		fx.Invoke(func(*A) {}),
+		fx.Invoke(func(p params) { got = p.Names }),
+	)
+	defer app.RequireStart().RequireStop()
+
+	assert.Equal(t, []string{"used"}, got)
+	assert.Equal(t, []string{"used"}, built, "soft groups shouldn't call constructors")
+}
+
+func TestOrderedGroups(t *testing.T) {
+	member := func(name string) func() string {
+		return func() string { return name }
+	}
+
+	t.Run("ProvideOrder", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Names []string `group:"names,ordered"`
+		}
+
+		var provides []interface{}
+		want := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+		for _, name := range want {
+			provides = append(provides, fx.Annotated{Group: "names", Target: member(name)})
+		}
+
+		var got []string
+		app := fxtest.New(t,
+			fx.Provide(provides...),
+			fx.Invoke(func(p params) { got = p.Names }),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("Priority", func(t *testing.T) {
+		type first struct {
+			fx.Out
+
+			Names []string `group:"names,flatten" priority:"-1"`
+		}
+
+		type params struct {
+			fx.In
+
+			Names []string `group:"names,ordered"`
+		}
+
+		var got []string
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotate(member("last"), fx.ResultTags(`group:"names" priority:"10"`)),
+				fx.Annotated{Group: "names", Target: member("middle")},
+				func() first { return first{Names: []string{"first", "second"}} },
+			),
+			fx.Invoke(fx.Annotate(func(names []string) { got = names }, fx.ParamTags(`group:"names,ordered"`))),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"first", "second", "middle", "last"}, got)
+	})
+
+	t.Run("UnorderedConsumersStillWork", func(t *testing.T) {
+		type ordered struct {
+			fx.In
+
+			Names []string `group:"names,ordered"`
+		}
+		type unordered struct {
+			fx.In
+
+			Names []string `group:"names"`
+		}
+
+		var got, all []string
+		app := fxtest.New(t,
+			fx.Provide(
+				fx.Annotated{Group: "names", Target: member("a")},
+				fx.Annotated{Group: "names", Target: member("b")},
+			),
+			fx.Invoke(func(p ordered) { got = p.Names }),
+			fx.Invoke(func(p unordered) { all = p.Names }),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"a", "b"}, got)
+		assert.ElementsMatch(t, []string{"a", "b"}, all)
+	})
+
+	t.Run("InvalidPriority", func(t *testing.T) {
+		type out struct {
+			fx.Out
+
+			Name string `group:"names" priority:"high"`
+		}
+		type params struct {
+			fx.In
+
+			Names []string `group:"names,ordered"`
+		}
+
+		err := fx.New(
+			fx.NopLogger,
+			fx.Provide(func() out { return out{} }),
+			fx.Invoke(func(params) {}),
+		).Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid priority "high"`)
+	})
+
+	t.Run("ErrorsNameFunctions", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Names []string `group:"names,ordered"`
+		}
+
+		err := fx.New(
+			fx.NopLogger,
+			fx.Provide(fx.Annotated{
+				Group:  "names",
+				Target: func() (string, error) { return "", errors.New("great sadness") },
+			}),
+			fx.Invoke(func(params) {}),
+		).Err()
+		require.Error(t, err)
+		assert.Regexp(t, `function "go.uber.org/fx_test".TestOrderedGroups.func\d+.\d+ \(.+/groups_test.go:\d+\): `+
+			`could not build value group string\[group="names"\]: `+
+			`received non-nil error from function "go.uber.org/fx_test".TestOrderedGroups.func\d+.\d+ \(.+/groups_test.go:\d+\)`,
+			err.Error())
+		assert.NotContains(t, err.Error(), "makeFuncStub")
+	})
+
+	t.Run("CannotDecorate", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Names []string `group:"names,ordered"`
+		}
+
+		err := fx.New(
+			fx.NopLogger,
+			fx.Provide(fx.Annotated{Group: "names", Target: member("a")}),
+			fx.Decorate(fx.Annotate(
+				func(names []string) []string { return append(names, "b") },
+				fx.ParamTags(`group:"names"`),
+				fx.ResultTags(`group:"names"`),
+			)),
+			fx.Invoke(func(params) {}),
+		).Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `cannot decorate value group "names" of string`)
+	})
+}
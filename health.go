@@ -0,0 +1,173 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// A HealthCheck reports whether one component of the application is
+// healthy. Check returns nil if it is.
+type HealthCheck struct {
+	Name  string
+	Check func(context.Context) error
+}
+
+// Health collects the liveness and readiness checks of an application.
+// Health is provided to all Fx applications.
+//
+// An application is live as long as all its liveness checks pass. It's ready
+// once Start has run every OnStart hook successfully, and as long as all its
+// readiness checks pass. It stops being ready as soon as Stop begins, or as
+// soon as it receives one of the signals that end it, or a shutdown from the
+// Shutdowner, so that load balancers stop sending it traffic before its
+// OnStop hooks run. Signals are only noticed by applications that listen for
+// them, through Run, Done or Wait.
+//
+// Health is also an http.Handler reporting the status as JSON, with a 200
+// status code if the application is live and ready and a 503 otherwise.
+// LivenessHandler only takes liveness into account.
+//
+//   func Register(mux *http.ServeMux, h fx.Health) {
+//     mux.Handle("/health", h)
+//     mux.Handle("/health/live", h.LivenessHandler())
+//   }
+//
+// 健康检查：组件可注册存活及就绪检查，app启动完成后就绪，开始停止或收到关闭信号后不再就绪
+type Health interface {
+	http.Handler
+
+	// AppendLiveness adds a check to find out whether the application is
+	// still working, or should be restarted.
+	AppendLiveness(HealthCheck)
+
+	// AppendReadiness adds a check to find out whether the application can
+	// handle requests.
+	AppendReadiness(HealthCheck)
+
+	// Status runs all checks and reports the result.
+	Status(context.Context) HealthStatus
+
+	// LivenessHandler returns an http.Handler that reports the status like
+	// Health does, but with a 200 status code whenever the application is
+	// live, whether or not it's ready.
+	LivenessHandler() http.Handler
+}
+
+// HealthStatus is the aggregated status of an application's health checks.
+type HealthStatus struct {
+	Live   bool                `json:"live"`
+	Ready  bool                `json:"ready"`
+	State  string              `json:"state"` // see App.State
+	Checks []HealthCheckResult `json:"checks,omitempty"`
+}
+
+// HealthCheckResult is the outcome of a single health check.
+type HealthCheckResult struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"` // "liveness" or "readiness"
+	Error string `json:"error,omitempty"`
+}
+
+type health struct {
+	app *App
+
+	mu        sync.Mutex
+	liveness  []HealthCheck
+	readiness []HealthCheck
+	ready     bool
+}
+
+func (h *health) AppendLiveness(c HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, c)
+}
+
+func (h *health) AppendReadiness(c HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, c)
+}
+
+func (h *health) Status(ctx context.Context) HealthStatus {
+	h.mu.Lock()
+	liveness, readiness, ready := h.liveness, h.readiness, h.ready
+	h.mu.Unlock()
+
+	s := HealthStatus{Live: true, Ready: ready, State: h.app.State().String()}
+	run := func(kind string, checks []HealthCheck) bool {
+		ok := true
+		for _, c := range checks {
+			r := HealthCheckResult{Name: c.Name, Kind: kind}
+			if err := c.Check(ctx); err != nil {
+				r.Error = err.Error()
+				ok = false
+			}
+			s.Checks = append(s.Checks, r)
+		}
+		return ok
+	}
+	s.Live = run("liveness", liveness)
+	if !run("readiness", readiness) {
+		s.Ready = false
+	}
+	return s
+}
+
+func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s := h.Status(r.Context())
+	h.write(w, s, s.Live && s.Ready)
+}
+
+func (h *health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := h.Status(r.Context())
+		h.write(w, s, s.Live)
+	})
+}
+
+func (h *health) write(w http.ResponseWriter, s HealthStatus, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(s)
+}
+
+// start marks the application ready until unready is called.
+func (h *health) start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = true
+}
+
+// unready marks the application as no longer ready.
+func (h *health) unready() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = false
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestHealth(t *testing.T) {
+	get := func(t *testing.T, h http.Handler) (int, fx.HealthStatus) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var s fx.HealthStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &s))
+		return rec.Code, s
+	}
+
+	t.Run("ReadyWhileStarted", func(t *testing.T) {
+		var health fx.Health
+		app := fxtest.New(t,
+			fx.Populate(&health),
+			fx.Invoke(func(lc fx.Lifecycle, h fx.Health) {
+				lc.Append(fx.Hook{
+					OnStart: func(ctx context.Context) error {
+						assert.False(t, h.Status(ctx).Ready, "must not be ready while starting")
+						return nil
+					},
+					OnStop: func(ctx context.Context) error {
+						assert.False(t, h.Status(ctx).Ready, "must not be ready while stopping")
+						return nil
+					},
+				})
+			}),
+		)
+
+		code, s := get(t, health)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, fx.HealthStatus{Live: true, State: "new"}, s)
+
+		app.RequireStart()
+		code, s = get(t, health)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, fx.HealthStatus{Live: true, Ready: true, State: "started"}, s)
+
+		app.RequireStop()
+		code, _ = get(t, health)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	})
+
+	t.Run("Checks", func(t *testing.T) {
+		var (
+			health   fx.Health
+			dbErr    error
+			stuckErr error
+		)
+		app := fxtest.New(t,
+			fx.Populate(&health),
+			fx.Invoke(func(h fx.Health) {
+				h.AppendLiveness(fx.HealthCheck{
+					Name:  "loop",
+					Check: func(context.Context) error { return stuckErr },
+				})
+				h.AppendReadiness(fx.HealthCheck{
+					Name:  "db",
+					Check: func(context.Context) error { return dbErr },
+				})
+			}),
+		)
+		app.RequireStart()
+		defer app.RequireStop()
+
+		code, s := get(t, health)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []fx.HealthCheckResult{
+			{Name: "loop", Kind: "liveness"},
+			{Name: "db", Kind: "readiness"},
+		}, s.Checks)
+
+		dbErr = errors.New("connection refused")
+		code, s = get(t, health)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.True(t, s.Live)
+		assert.False(t, s.Ready)
+		assert.Equal(t, "connection refused", s.Checks[1].Error)
+
+		code, _ = get(t, health.LivenessHandler())
+		assert.Equal(t, http.StatusOK, code, "liveness ignores readiness checks")
+
+		stuckErr = errors.New("stuck")
+		code, s = get(t, health.LivenessHandler())
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.False(t, s.Live)
+	})
+
+	t.Run("ShutdownSignal", func(t *testing.T) {
+		var (
+			health     fx.Health
+			shutdowner fx.Shutdowner
+		)
+		app := fxtest.New(t, fx.Populate(&health, &shutdowner))
+		app.RequireStart()
+		defer app.RequireStop()
+
+		require.NoError(t, shutdowner.Shutdown())
+		assert.False(t, health.Status(context.Background()).Ready)
+	})
+
+	t.Run("OSSignal", func(t *testing.T) {
+		var health fx.Health
+		app := fxtest.New(t, fx.Signals(syscall.SIGUSR1), fx.Populate(&health))
+		done := app.Done()
+		app.RequireStart()
+		defer app.RequireStop()
+
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+		<-done
+		assert.Eventually(t, func() bool {
+			return !health.Status(context.Background()).Ready
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("OSSignalWithoutListeners", func(t *testing.T) {
+		// SIGWINCH is ignored by default, so the test survives if the
+		// application doesn't listen for it.
+		var health fx.Health
+		app := fxtest.New(t, fx.Signals(syscall.SIGWINCH), fx.Populate(&health))
+		app.RequireStart()
+		defer app.RequireStop()
+
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGWINCH))
+		time.Sleep(10 * time.Millisecond)
+		assert.True(t, health.Status(context.Background()).Ready,
+			"applications that don't listen for signals mustn't catch them")
+	})
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"fx-master/internal/fxreflect"
+	"go.uber.org/dig"
+)
+
+var _typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// StartHook returns a Hook that runs the given function on start. The
+// function may have any of these signatures:
+//
+//   func()
+//   func() error
+//   func(context.Context)
+//   func(context.Context) error
+//
+// so that constructors can append existing functions without wrapping them:
+//
+//   func NewServer(lc fx.Lifecycle, srv *http.Server) *Server {
+//     lc.Append(fx.StopHook(srv.Shutdown))
+//     ...
+//   }
+//
+// A function with any other signature fails the application when it runs.
+func StartHook(start interface{}) Hook {
+	return StartStopHook(start, nil)
+}
+
+// StopHook returns a Hook that runs the given function on stop. It accepts
+// the same signatures as StartHook.
+func StopHook(stop interface{}) Hook {
+	return StartStopHook(nil, stop)
+}
+
+// StartStopHook returns a Hook that runs the given functions on start and
+// stop. Either may be nil. It accepts the same signatures as StartHook.
+//
+// StartStopHook及StartHook/StopHook支持func()、func() error、func(ctx)、func(ctx) error等多种签名
+func StartStopHook(start, stop interface{}) Hook {
+	var h Hook
+	if start != nil {
+		h.OnStart, h.onStartName = hookFunc("OnStart", start), fxreflect.FuncName(start)
+	}
+	if stop != nil {
+		h.OnStop, h.onStopName = hookFunc("OnStop", stop), fxreflect.FuncName(stop)
+	}
+	return h
+}
+
+// hookFunc adapts a function with one of the signatures accepted by
+// StartHook to the signature used by Hook. Other functions become hooks
+// that fail.
+func hookFunc(phase string, f interface{}) func(context.Context) error {
+	v := reflect.ValueOf(f)
+	if !isHookFunc(v.Type()) {
+		return func(context.Context) error {
+			return fmt.Errorf("%v hook %v has an unsupported signature %v: "+
+				"expected func(), func() error, func(context.Context) or func(context.Context) error",
+				phase, fxreflect.FuncName(f), v.Type())
+		}
+	}
+
+	return func(ctx context.Context) error {
+		var args []reflect.Value
+		if v.Type().NumIn() == 1 {
+			args = []reflect.Value{reflect.ValueOf(&ctx).Elem()}
+		}
+		results := v.Call(args)
+		if len(results) == 1 && !results[0].IsNil() {
+			return results[0].Interface().(error)
+		}
+		return nil
+	}
+}
+
+// isHookFunc reports whether t is one of the function types accepted by
+// StartHook.
+func isHookFunc(t reflect.Type) bool {
+	if t == nil || t.Kind() != reflect.Func || t.IsVariadic() {
+		return false
+	}
+	switch {
+	case t.NumIn() > 1,
+		t.NumIn() == 1 && t.In(0) != _typeOfContext,
+		t.NumOut() > 1,
+		t.NumOut() == 1 && t.Out(0) != _typeOfError:
+		return false
+	}
+	return true
+}
+
+// RegisterHooks is an Option that calls the Start and Stop methods of
+// already provided values when the application starts and stops. Targets
+// are pointers to the types of those values, as with Populate:
+//
+//   fx.Provide(NewServer),
+//   fx.RegisterHooks(new(*Server)),
+//
+// Is equivalent to,
+//
+//   fx.Invoke(func(lc fx.Lifecycle, s *Server) {
+//     lc.Append(fx.StartStopHook(s.Start, s.Stop))
+//   })
+//
+// Each type must have a Start method, a Stop method, or both, with any of
+// the signatures accepted by StartHook.
+//
+// RegisterHooks在application启动和停止时调用已提供类型的Start/Stop方法
+func RegisterHooks(targets ...interface{}) Option {
+	types := make([]reflect.Type, len(targets))
+	for i, t := range targets {
+		rt := reflect.TypeOf(t)
+		if rt == nil || rt.Kind() != reflect.Ptr {
+			return invokeErr(fmt.Errorf("failed to RegisterHooks: target %v is not a pointer type, got %T", i+1, t))
+		}
+		types[i] = rt.Elem()
+
+		start, hasStart := methodType(types[i], "Start")
+		stop, hasStop := methodType(types[i], "Stop")
+		switch {
+		case !hasStart && !hasStop:
+			return invokeErr(fmt.Errorf("failed to RegisterHooks: %v has no Start or Stop method", types[i]))
+		case hasStart && !isHookFunc(start):
+			return invokeErr(fmt.Errorf("failed to RegisterHooks: %v.Start has an unsupported signature %v", types[i], start))
+		case hasStop && !isHookFunc(stop):
+			return invokeErr(fmt.Errorf("failed to RegisterHooks: %v.Stop has an unsupported signature %v", types[i], stop))
+		}
+	}
+
+	// Build a function that looks like:
+	//
+	// func(lc Lifecycle, t1 T1, t2 T2, ...) {
+	//   lc.Append(StartStopHook(t1.Start, t1.Stop))
+	//   [...]
+	// }
+	params := append([]reflect.Type{_typeOfLifecycle}, types...)
+	fn := reflect.MakeFunc(reflect.FuncOf(params, nil, false), func(args []reflect.Value) []reflect.Value {
+		lc := args[0].Interface().(Lifecycle)
+		for _, arg := range args[1:] {
+			lc.Append(methodHook(arg, "fx.RegisterHooks", true, true))
+		}
+		return nil
+	})
+	return Invoke(fn.Interface())
+}
+
+var _typeOfLifecycle = reflect.TypeOf((*Lifecycle)(nil)).Elem()
+
+// methodType returns the type of the named method of t, without its
+// receiver.
+func methodType(t reflect.Type, name string) (reflect.Type, bool) {
+	m, ok := t.MethodByName(name)
+	if !ok {
+		return nil, false
+	}
+	if t.Kind() == reflect.Interface {
+		return m.Type, true
+	}
+
+	in := make([]reflect.Type, m.Type.NumIn()-1)
+	for i := range in {
+		in[i] = m.Type.In(i + 1)
+	}
+	out := make([]reflect.Type, m.Type.NumOut())
+	for i := range out {
+		out[i] = m.Type.Out(i)
+	}
+	return reflect.FuncOf(in, out, m.Type.IsVariadic()), true
+}
+
+// methodHook returns a Hook that calls the Start and Stop methods of v, if
+// asked to and if they exist.
+func methodHook(v reflect.Value, caller string, withStart, withStop bool) Hook {
+	var start, stop interface{}
+	if m := v.MethodByName("Start"); withStart && m.IsValid() {
+		start = m.Interface()
+	}
+	if m := v.MethodByName("Stop"); withStop && m.IsValid() {
+		stop = m.Interface()
+	}
+
+	h := StartStopHook(start, stop)
+	if start != nil {
+		h.onStartName = fmt.Sprintf("%v.Start", v.Type())
+	}
+	if stop != nil {
+		h.onStopName = fmt.Sprintf("%v.Stop", v.Type())
+	}
+	h.callerName = caller
+	return h
+}
+
+// Starter is implemented by values that start along with the application.
+// See AutoHooks.
+type Starter interface {
+	Start(context.Context) error
+}
+
+// Stopper is implemented by values that stop along with the application.
+// See AutoHooks.
+type Stopper interface {
+	Stop(context.Context) error
+}
+
+// AutoHooks is an Option that registers lifecycle hooks for every value
+// built by a constructor that implements Starter, Stopper, or both. The
+// hooks run in the order the values were built, as if each constructor had
+// appended them itself.
+//
+// Values sent to value groups and fields of fx.Out structs are included.
+// Don't combine AutoHooks with RegisterHooks for the same type, or its
+// methods will run twice.
+//
+// 开启后 构造函数返回的值若实现了Starter/Stopper接口 会自动注册对应的hook
+var AutoHooks Option = autoHooksOption{}
+
+type autoHooksOption struct{}
+
+func (autoHooksOption) apply(app *App) {
+	app.autoHooks = true
+}
+
+func (autoHooksOption) String() string { return "fx.AutoHooks" }
+
+// wrapAutoHooks returns a constructor that appends hooks for its results
+// that implement Starter or Stopper.
+func (app *App) wrapAutoHooks(fn interface{}, name string) interface{} {
+	ft := reflect.TypeOf(fn)
+	if !app.autoHooks || ft == nil || ft.Kind() != reflect.Func {
+		return fn
+	}
+
+	call := reflect.ValueOf(fn).Call
+	if ft.IsVariadic() {
+		call = reflect.ValueOf(fn).CallSlice
+	}
+	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		results := call(args)
+		if n := len(results); n > 0 && ft.Out(n-1) == _typeOfError && !results[n-1].IsNil() {
+			return results
+		}
+		for _, r := range results {
+			app.appendAutoHooks(r, name)
+		}
+		return results
+	}).Interface()
+}
+
+// appendAutoHooks appends hooks for v, or for the fields of v if it's an
+// fx.Out struct.
+func (app *App) appendAutoHooks(v reflect.Value, caller string) {
+	t := v.Type()
+	if t.Kind() == reflect.Struct && dig.IsOut(t) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || (f.Anonymous && isMarker(f.Type)) {
+				continue
+			}
+			fv := v.Field(i)
+			if _, opts := groupNames(f.Tag.Get("group")); hasOption(opts, "flatten") && fv.Kind() == reflect.Slice {
+				for j := 0; j < fv.Len(); j++ {
+					app.appendAutoHooks(fv.Index(j), caller)
+				}
+				continue
+			}
+			app.appendAutoHooks(fv, caller)
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			return
+		}
+	}
+	if !v.CanInterface() {
+		return
+	}
+	_, isStarter := v.Interface().(Starter)
+	_, isStopper := v.Interface().(Stopper)
+	if !isStarter && !isStopper {
+		return
+	}
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	app.lifecycle.Append(methodHook(v, caller, isStarter, isStopper))
+}
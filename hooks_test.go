@@ -0,0 +1,266 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+// service records calls to its Start and Stop methods.
+type service struct {
+	name   string
+	events *[]string
+}
+
+func (s *service) Start(context.Context) error {
+	*s.events = append(*s.events, s.name+" start")
+	return nil
+}
+
+func (s *service) Stop(context.Context) error {
+	*s.events = append(*s.events, s.name+" stop")
+	return nil
+}
+
+func TestStartStopHook(t *testing.T) {
+	t.Run("Signatures", func(t *testing.T) {
+		var events []string
+		record := func(e string) { events = append(events, e) }
+
+		app := fxtest.New(t, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.StartHook(func() { record("func()") }))
+			lc.Append(fx.StartHook(func() error { record("func() error"); return nil }))
+			lc.Append(fx.StartHook(func(context.Context) { record("func(ctx)") }))
+			lc.Append(fx.StartStopHook(
+				func(context.Context) error { record("func(ctx) error"); return nil },
+				func() { record("stop") },
+			))
+			lc.Append(fx.StopHook(func() error { record("stop error"); return nil }))
+		}))
+		app.RequireStart().RequireStop()
+
+		assert.Equal(t, []string{
+			"func()", "func() error", "func(ctx)", "func(ctx) error",
+			"stop error", "stop",
+		}, events)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.StartHook(func() error { return errors.New("great sadness") }))
+		}))
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+	})
+
+	t.Run("UnsupportedSignature", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.StartHook(func(int) error { return nil }))
+		}))
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported signature func(int) error")
+	})
+
+	t.Run("ReportsFunctionName", func(t *testing.T) {
+		app := fxtest.New(t, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.StartHook(func() {}))
+		}))
+		app.RequireStart()
+		defer app.RequireStop()
+
+		hooks := app.StartupReport().Hooks
+		require.Len(t, hooks, 1)
+		assert.Contains(t, hooks[0].FunctionName, "TestStartStopHook")
+		assert.Contains(t, hooks[0].CallerName, "TestStartStopHook")
+	})
+}
+
+func TestRegisterHooks(t *testing.T) {
+	t.Run("CallsMethods", func(t *testing.T) {
+		var events []string
+		app := fxtest.New(t,
+			fx.Provide(func() *service { return &service{name: "svc", events: &events} }),
+			fx.RegisterHooks(new(*service)),
+		)
+		app.RequireStart()
+		assert.Equal(t, []string{"svc start"}, events)
+		app.RequireStop()
+		assert.Equal(t, []string{"svc start", "svc stop"}, events)
+
+		hooks := app.StartupReport().Hooks
+		require.Len(t, hooks, 1)
+		assert.Equal(t, "*fx_test.service.Start", hooks[0].FunctionName)
+	})
+
+	t.Run("FlexibleSignatures", func(t *testing.T) {
+		var started bool
+		type starter interface{ Start() }
+		app := fxtest.New(t,
+			fx.Provide(func() starter { return startFunc(func() { started = true }) }),
+			fx.RegisterHooks(new(starter)),
+		)
+		app.RequireStart().RequireStop()
+		assert.True(t, started)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		type noMethods struct{}
+
+		tests := []struct {
+			desc string
+			give interface{}
+			want string
+		}{
+			{desc: "not a pointer", give: service{}, want: "is not a pointer type"},
+			{desc: "no methods", give: new(noMethods), want: "has no Start or Stop method"},
+			{desc: "bad signature", give: new(badStart), want: "Start has an unsupported signature func() string"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				err := fx.New(fx.NopLogger, fx.RegisterHooks(tt.give)).Err()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want)
+			})
+		}
+	})
+}
+
+type startFunc func()
+
+func (f startFunc) Start() { f() }
+
+type badStart struct{}
+
+func (badStart) Start() string { return "" }
+
+func TestAutoHooks(t *testing.T) {
+	type out struct {
+		fx.Out
+
+		B *service `name:"b"`
+		C *service `group:"services"`
+	}
+
+	var events []string
+	app := fxtest.New(t,
+		fx.AutoHooks,
+		fx.Provide(
+			func() *service { return &service{name: "a", events: &events} },
+			func(*service) out {
+				return out{
+					B: &service{name: "b", events: &events},
+					C: &service{name: "c", events: &events},
+				}
+			},
+		),
+		fx.Invoke(func(struct {
+			fx.In
+
+			B        *service   `name:"b"`
+			Services []*service `group:"services"`
+		}) {
+		}),
+	)
+	app.RequireStart().RequireStop()
+	assert.Equal(t, []string{"a start", "b start", "c start", "c stop", "b stop", "a stop"}, events)
+}
+
+func TestAutoHooksAnnotated(t *testing.T) {
+	var events []string
+	app := fxtest.New(t,
+		fx.AutoHooks,
+		fx.Provide(
+			fx.Annotated{
+				Name:   "a",
+				Target: func() *service { return &service{name: "a", events: &events} },
+			},
+			fx.Annotated{
+				Group:  "services",
+				Target: func() *service { return &service{name: "b", events: &events} },
+			},
+			fx.Annotate(
+				func() *service { return &service{name: "c", events: &events} },
+				fx.ResultTags(`name:"c"`),
+			),
+		),
+		fx.Invoke(func(struct {
+			fx.In
+
+			A        *service   `name:"a"`
+			C        *service   `name:"c"`
+			Services []*service `group:"services"`
+		}) {
+		}),
+	)
+	app.RequireStart().RequireStop()
+	assert.ElementsMatch(t, []string{
+		"a start", "b start", "c start",
+		"a stop", "b stop", "c stop",
+	}, events)
+}
+
+func newFailingService() (*service, error) { return nil, errors.New("great sadness") }
+
+func TestAutoHooksErrorsNameConstructor(t *testing.T) {
+	tests := []struct {
+		desc        string
+		constructor interface{}
+		invoke      interface{}
+	}{
+		{
+			desc:        "Plain",
+			constructor: newFailingService,
+			invoke:      func(*service) {},
+		},
+		{
+			desc:        "Annotated",
+			constructor: fx.Annotated{Name: "a", Target: newFailingService},
+			invoke: func(struct {
+				fx.In
+
+				A *service `name:"a"`
+			}) {
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := fx.New(
+				fx.NopLogger,
+				fx.AutoHooks,
+				fx.Provide(tt.constructor),
+				fx.Invoke(tt.invoke),
+			).Err()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), `received non-nil error from function "go.uber.org/fx_test".newFailingService (`)
+			assert.NotContains(t, err.Error(), "makeFuncStub")
+		})
+	}
+}
@@ -248,6 +248,40 @@ import "go.uber.org/dig"
 //
 // Note that values in a value group are unordered. Fx makes no guarantees
 // about the order in which these values will be produced.
+//
+// Soft Value Groups
+//
+// A group tagged with the "soft" option only receives values from
+// constructors that were already called to satisfy other dependencies. It
+// never causes a constructor to run on its own.
+//
+//   type ServerParams struct {
+//     fx.In
+//
+//     Handlers []Handler `group:"server,soft"`
+//   }
+//
+// Ordered Value Groups
+//
+// A group tagged with the "ordered" option receives its values in a
+// deterministic order: by the priority each value was given when it was
+// provided, lowest first, and then in the order their constructors were
+// passed to fx.Provide. Values without a priority have priority 0.
+//
+//   type HandlerResult struct {
+//     fx.Out
+//
+//     Handler Handler `group:"server" priority:"10"`
+//   }
+//
+//   type ServerParams struct {
+//     fx.In
+//
+//     Handlers []Handler `group:"server,ordered"`
+//   }
+//
+// Ordered groups may also be soft. Ordered groups can't be decorated: fx.New
+// fails if a decorator's results include a group that's consumed in order.
 type In struct{ dig.In }
 
 // Fx.Out是Fx.In相反面。
@@ -391,4 +425,14 @@ type In struct{ dig.In }
 // value groups require parameter and result structs to use fields with
 // different types: if a group of constructors each returns type T, parameter
 // structs consuming the group must use a field of type []T.
+//
+// A value may be sent to several value groups by listing them in one tag, and
+// may be both named and in value groups. The constructor is still called
+// once, and every name and group receives the same value.
+//
+//   type HandlerResult struct {
+//     fx.Out
+//
+//     Handler Handler `name:"hello" group:"server,admin"`
+//   }
 type Out struct{ dig.Out }
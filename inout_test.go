@@ -129,3 +129,52 @@ func TestNamedTypes(t *testing.T) {
 	app.RequireStart().RequireStop()
 	assert.True(t, ran, "expected invoke to run")
 }
+
+func TestNamedGroupTypes(t *testing.T) {
+	type handler struct {
+		name string
+	}
+
+	type out struct {
+		fx.Out
+
+		Hello *handler `name:"hello" group:"routes,admin"`
+	}
+
+	type nested struct {
+		fx.Out
+
+		Inner out
+		Echo  []*handler `name:"echo" group:"routes,flatten"`
+	}
+
+	type in struct {
+		fx.In
+
+		Hello  *handler   `name:"hello"`
+		Echo   []*handler `name:"echo"`
+		Routes []*handler `group:"routes"`
+		Admin  []*handler `group:"admin"`
+	}
+
+	var calls int
+	newHandlers := func() nested {
+		calls++
+		return nested{
+			Inner: out{Hello: &handler{name: "hello"}},
+			Echo:  []*handler{{name: "echo1"}, {name: "echo2"}},
+		}
+	}
+
+	ran := false
+	app := fxtest.New(t, fx.Provide(newHandlers), fx.Invoke(func(in in) {
+		assert.Equal(t, "hello", in.Hello.name)
+		assert.Len(t, in.Echo, 2)
+		assert.ElementsMatch(t, []*handler{in.Hello, in.Echo[0], in.Echo[1]}, in.Routes)
+		assert.Equal(t, []*handler{in.Hello}, in.Admin)
+		ran = true
+	}))
+	app.RequireStart().RequireStop()
+	assert.True(t, ran, "expected invoke to run")
+	assert.Equal(t, 1, calls, "expected the constructor to run once")
+}
@@ -123,13 +123,30 @@ func sanitize(function string) string {
 // Caller returns the formatted calling func name
 // 对调用函数名称进行格式化: 输出函数调用链(会剔除本框架内调用链)
 func Caller() string {
+	f, ok := callerFrame()
+	if !ok {
+		return "n/a"
+	}
+	return sanitize(f.Function) // 函数完整路径
+}
+
+// CallerPC returns the program counter of the call into fx, suitable for
+// dig.LocationForPC, or 0 if it can't be found.
+// 返回调用fx处的pc
+func CallerPC() uintptr {
+	f, _ := callerFrame()
+	return f.PC
+}
+
+// callerFrame returns the first frame outside fx that called into it.
+func callerFrame() (runtime.Frame, bool) {
 	// Ascend at most 8 frames looking for a caller outside fx.
 	pcs := make([]uintptr, 8)
 
-	// Don't include this frame.
-	n := runtime.Callers(2, pcs) // 剔除本框架的调用
+	// Don't include this frame or its caller in this package.
+	n := runtime.Callers(3, pcs) // 剔除本框架的调用
 	if n == 0 {
-		return "n/a"
+		return runtime.Frame{}, false
 	}
 
 	frames := runtime.CallersFrames(pcs)  // 获取到调用链
@@ -137,9 +154,9 @@ func Caller() string {
 		if shouldIgnoreFrame(f) {
 			continue
 		}
-		return sanitize(f.Function)  // 函数完整路径
+		return f, true
 	}
-	return "n/a"
+	return runtime.Frame{}, false
 }
 
 // FuncName returns a funcs formatted name
@@ -154,6 +171,34 @@ func FuncName(fn interface{}) string {
 	return fmt.Sprintf("%s()", sanitize(function)) // 输出：类似vender/xxx/xxx/xxx.function()
 }
 
+// Location describes the function at the given program counter the way dig
+// does in its errors, like "path/to/pkg".Func (path/to/file.go:42).
+func Location(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return ""
+	}
+
+	// Everything up to the first "." after the last "/" is the package.
+	name, idx := f.Name(), 0
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		idx = i
+	}
+	if i := strings.Index(name[idx:], "."); i >= 0 {
+		idx += i
+	}
+	pkg, fn := name[:idx], name[idx+1:]
+	if i := strings.Index(pkg, "/vendor/"); i > 0 {
+		pkg = pkg[i+len("/vendor/"):]
+	}
+	if unescaped, err := url.QueryUnescape(pkg); err == nil {
+		pkg = unescaped
+	}
+
+	file, line := f.FileLine(pc)
+	return fmt.Sprintf("%q.%v (%v:%v)", pkg, fn, file, line)
+}
+
 // 是否实现error接口
 func isErr(t reflect.Type) bool {
 	errInterface := reflect.TypeOf((*error)(nil)).Elem()
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -89,6 +90,11 @@ func TestCaller(t *testing.T) {
 	assert.Equal(t, "go.uber.org/fx/internal/fxreflect.TestCaller", Caller())
 }
 
+func TestCallerPC(t *testing.T) {
+	assert.Regexp(t, `^"go.uber.org/fx/internal/fxreflect".TestCallerPC \(.+/fxreflect_test.go:\d+\)$`,
+		Location(CallerPC()))
+}
+
 func someFunc() {}
 
 func TestFuncName(t *testing.T) {
@@ -96,6 +102,12 @@ func TestFuncName(t *testing.T) {
 	assert.Equal(t, "n/a", FuncName(struct{}{}))
 }
 
+func TestLocation(t *testing.T) {
+	assert.Regexp(t, `^"go.uber.org/fx/internal/fxreflect".someFunc \(.+/fxreflect_test.go:\d+\)$`,
+		Location(reflect.ValueOf(someFunc).Pointer()))
+	assert.Empty(t, Location(0))
+}
+
 func TestSanitizeFuncNames(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -22,8 +22,15 @@ package lifecycle
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
 
-	"fx-master/internal/fxlog"
+	"fx-master/fxevent"
 	"fx-master/internal/fxreflect"
 	"go.uber.org/multierr"
 )
@@ -33,65 +40,650 @@ import (
 // A Hook is a pair of start and stop callbacks, either of which can be nil,
 // plus a string identifying the supplier of the hook.
 type Hook struct {
-	OnStart func(context.Context) error
-	OnStop  func(context.Context) error
-	caller  string
+	OnStart        func(context.Context) error
+	OnStop         func(context.Context) error
+	OnDrain        func(context.Context) error // see Drain
+	Stage          int                         // see Start
+	OnStartTimeout time.Duration
+	OnStopTimeout  time.Duration
+
+	// OnStartName, OnStopName and CallerName replace the names that would
+	// otherwise be derived from the functions and the call to Append, for
+	// hooks that wrap another function.
+	OnStartName string
+	OnStopName  string
+	CallerName  string
+
+	caller string
+}
+
+// HookRecord describes a single run of an OnStart or OnStop hook.
+// 记录单个hook的执行情况：调用方、耗时以及产生的error
+type HookRecord struct {
+	CallerName   string
+	FunctionName string
+	Runtime      time.Duration
+	Err          error
 }
 
 // 用于协调app中的定义hooks
 // Lifecycle coordinates application lifecycle hooks.
 type Lifecycle struct {
-	logger     *fxlog.Logger   // 操作记录
-	hooks      []Hook          // app中开启的hook
-	numStarted int             // 已开启的hook???
+	logger        fxevent.Logger  // 操作记录
+	slowThreshold time.Duration   // 超过该耗时的hook会被警告
+	hooks         []Hook          // app中开启的hook
+	started       []int           // 已开启的hook的下标，按开启顺序排列
+	deps          func() [][]int  // 非nil时并行执行hooks
+	onPanic       PanicHandler    // 非nil时hook中的panic会转换为error
+
+	// Hooks may outlive the context passed to Start or Stop, may run
+	// concurrently, and may append other hooks, so everything below is
+	// guarded by mu.
+	mu           sync.Mutex
+	phase        phase                     // what the lifecycle is doing
+	lazy         sync.WaitGroup            // OnStart hooks run by AppendStarted
+	running      map[*runningHook]struct{} // hooks currently executing
+	startRecords []HookRecord
+	stopRecords  []HookRecord
+
+	logMu sync.Mutex // serializes calls to logger
+}
+
+// phase is the state of a Lifecycle.
+type phase int
+
+const (
+	idle     phase = iota // not started, or stopped
+	starting              // running OnStart hooks
+	started               // every OnStart hook succeeded
+	stopping              // running OnStop hooks
+)
+
+type runningHook struct {
+	rec   HookRecord
+	since time.Time
 }
 
-// New constructs a new Lifecycle.
-func New(logger *fxlog.Logger) *Lifecycle {  // 创建Liftcycle
+// New constructs a new Lifecycle. Hook events are written to standard error
+// if no logger is given.
+func New(logger fxevent.Logger) *Lifecycle {  // 创建Liftcycle
 	if logger == nil {
-		logger = fxlog.New()
+		logger = &fxevent.ConsoleLogger{W: os.Stderr}
 	}
 	return &Lifecycle{logger: logger}  // 新建Liftcycle并附带logger
 }
 
+// SetSlowThreshold makes the lifecycle log a warning for every hook that
+// runs for longer than the given duration. Zero disables the warnings.
+func (l *Lifecycle) SetSlowThreshold(d time.Duration) {
+	l.slowThreshold = d
+}
+
+// SetParallel makes Start and Stop run independent hooks concurrently.
+//
+// At the beginning of each Start, deps is called to get, for every hook, the
+// indices of the earlier hooks it depends on. Hooks missing from the result
+// depend on all hooks before them. Start runs hooks in waves: a hook's
+// OnStart runs once the OnStart hooks of all its dependencies have succeeded.
+// Stop runs the same waves in reverse, so a hook's OnStop runs before those
+// of its dependencies.
+func (l *Lifecycle) SetParallel(deps func() [][]int) {
+	l.deps = deps
+}
+
+// PanicHandler turns a panic in a hook into an error. It receives the name
+// of the hook function, the caller that appended it, the value passed to
+// panic and the stack of the panicking goroutine.
+type PanicHandler func(fname, caller string, value interface{}, stack []byte) error
+
+// SetPanicHandler makes the lifecycle recover from panics in hooks, turning
+// them into errors with the given handler. Without a handler, panics in
+// hooks crash the program.
+func (l *Lifecycle) SetPanicHandler(h PanicHandler) {
+	l.onPanic = h
+}
+
 // Append adds a Hook to the lifecycle.
+//
+// Hooks appended by an OnStart hook while Start is running are started in
+// the same call to Start, after the hooks already appended, and stopped by
+// Stop like any other. Hooks appended after Start returns, or while Stop is
+// running, only run the next time the lifecycle starts; see AppendStarted.
 func (l *Lifecycle) Append(hook Hook) {  // app生命周期中新增新的hook
-	hook.caller = fxreflect.Caller()     // 每个调用帧的完整调用链
+	hook.caller = hook.CallerName
+	if hook.caller == "" {
+		hook.caller = fxreflect.Caller() // 每个调用帧的完整调用链
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// AppendStarted adds a Hook for a component created after the lifecycle
+// started. If Start has already returned successfully, the hook's OnStart
+// runs right away with the given context, and if it succeeds, its OnStop
+// runs during the next Stop, before the hooks started earlier. Otherwise,
+// AppendStarted behaves like Append. It fails while Stop is running.
+//
+// Start之后才创建的组件可通过AppendStarted注册hook：立刻执行OnStart 并在Stop时正常关闭
+func (l *Lifecycle) AppendStarted(ctx context.Context, hook Hook) error {
+	hook.caller = hook.CallerName
+	if hook.caller == "" {
+		hook.caller = fxreflect.Caller()
+	}
+
+	l.mu.Lock()
+	switch l.phase {
+	case stopping:
+		l.mu.Unlock()
+		return errors.New("can't start a hook while the lifecycle is stopping")
+	case idle, starting:
+		l.hooks = append(l.hooks, hook)
+		l.mu.Unlock()
+		return nil
+	}
+	// Start and Stop wait for the hook, so it can append hooks of its own.
 	l.hooks = append(l.hooks, hook)
+	i := len(l.hooks) - 1
+	l.lazy.Add(1)
+	l.mu.Unlock()
+	defer l.lazy.Done()
+
+	if hook.OnStart != nil {
+		if err := l.runOnStart(ctx, i); err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	l.started = append(l.started, i)
+	l.mu.Unlock()
+	return nil
+}
+
+// hook returns the i-th hook.
+func (l *Lifecycle) hook(i int) Hook {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hooks[i]
+}
+
+// numHooks returns the number of hooks appended so far.
+func (l *Lifecycle) numHooks() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.hooks)
+}
+
+func (l *Lifecycle) setPhase(p phase) {
+	l.mu.Lock()
+	l.phase = p
+	l.mu.Unlock()
 }
 
 // Start runs all OnStart hooks, returning immediately if it encounters an
 // error.
+//
+// Hooks run one stage at a time, in increasing order of their Stage: every
+// hook of a stage has started before any hook of the next stage does.
+// Within a stage, hooks run in the order they were appended.
 // 启动所有的hook；不过任意一个hook启动过程中产生了error都会导致程序立马结束
 func (l *Lifecycle) Start(ctx context.Context) error {
-	for _, hook := range l.hooks {
-		if hook.OnStart != nil {
-			l.logger.Printf("START\t\t%s()", hook.caller)
-			if err := hook.OnStart(ctx); err != nil { // 逐一启动hook的Start 并记录到liftcycle的hooks 切片中
+	l.mu.Lock()
+	l.startRecords = nil
+	l.phase = starting
+	l.mu.Unlock()
+	l.lazy.Wait()
+
+	err := l.start(ctx)
+	if err == nil {
+		l.setPhase(started)
+	}
+	return err
+}
+
+// start runs the hooks stage by stage. Hooks may append more hooks, so the
+// next hooks to run are looked up again after every round. A hook appended
+// for a stage that already ran is started in the current one.
+func (l *Lifecycle) start(ctx context.Context) error {
+	seen := make(map[int]bool)
+	for {
+		round := l.nextStage(seen)
+		if len(round) == 0 {
+			return nil
+		}
+
+		if l.deps != nil {
+			if err := l.startWaves(ctx, round); err != nil {
 				return err
 			}
+			continue
+		}
+
+		for _, i := range round {
+			if l.hook(i).OnStart != nil {
+				// 逐一启动hook的Start 并记录到liftcycle的hooks 切片中
+				if err := l.runOnStart(ctx, i); err != nil {
+					return err
+				}
+			}
+			l.started = append(l.started, i)  // 记录已完成开启的hook
+		}
+	}
+}
+
+// nextStage returns the hooks of the earliest stage among those that aren't
+// in seen, in the order they were appended, and adds them to seen.
+func (l *Lifecycle) nextStage(seen map[int]bool) []int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var round []int
+	for i, hook := range l.hooks {
+		switch {
+		case seen[i]:
+		case len(round) == 0 || hook.Stage < l.hooks[round[0]].Stage:
+			round = append(round[:0], i)
+		case hook.Stage == l.hooks[round[0]].Stage:
+			round = append(round, i)
+		}
+	}
+	for _, i := range round {
+		seen[i] = true
+	}
+	return round
+}
+
+func (l *Lifecycle) startWaves(ctx context.Context, hooks []int) error {
+	for _, wave := range waves(l.dependencies(), hooks) {
+		errs := make([]error, len(wave))
+		var wg sync.WaitGroup
+		for j, i := range wave {
+			if l.hook(i).OnStart == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(j, i int) {
+				defer wg.Done()
+				errs[j] = l.runOnStart(ctx, i)
+			}(j, i)
+		}
+		wg.Wait()
+
+		// Hooks that succeeded alongside a failed one still need to be
+		// stopped during rollback.
+		for j, i := range wave {
+			if errs[j] == nil {
+				l.started = append(l.started, i)
+			}
+		}
+		if err := multierr.Combine(errs...); err != nil {
+			return err
 		}
-		l.numStarted++  // 记录已完成开启的hook
 	}
 	return nil
 }
 
+// Drain runs the OnDrain hooks of the hooks that started, in reverse order,
+// to let them finish their work before Stop. Like Stop, it keeps going
+// after errors.
+// 在Stop之前执行已启动hook的OnDrain 用于处理完进行中的请求
+func (l *Lifecycle) Drain(ctx context.Context) error {
+	l.mu.Lock()
+	started := append([]int(nil), l.started...)
+	l.mu.Unlock()
+
+	var errs []error
+	for j := len(started) - 1; j >= 0; j-- {
+		if l.hook(started[j]).OnDrain == nil {
+			continue
+		}
+		if err := l.runOnDrain(ctx, started[j]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// HasDrainHooks reports whether any hook that started has an OnDrain hook.
+func (l *Lifecycle) HasDrainHooks() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, i := range l.started {
+		if l.hooks[i].OnDrain != nil {
+			return true
+		}
+	}
+	return false
+}
+
 // Stop runs any OnStop hooks whose OnStart counterpart succeeded. OnStop
-// hooks run in reverse order.
+// hooks run in reverse order, so stages are stopped one at a time, starting
+// with the last one that started.
 // 停止任意hook(需要当前hook已经启动了start)
 func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	l.stopRecords = nil
+	l.phase = stopping
+	l.mu.Unlock()
+	defer l.setPhase(idle)
+
+	// Hooks started by AppendStarted are stopped too, so let them finish.
+	l.lazy.Wait()
+
+	if l.deps != nil {
+		return l.stopParallel(ctx)
+	}
+
 	var errs []error
 	// Run backward from last successful OnStart.
-	for ; l.numStarted > 0; l.numStarted-- {  // 从上一次成功的OnStart处开始 往后处理对应的hook
-		hook := l.hooks[l.numStarted-1]  // numStarted记录成功执行的OnStart
-		if hook.OnStop == nil {
+	for ; len(l.started) > 0; l.started = l.started[:len(l.started)-1] {  // 从上一次成功的OnStart处开始 往后处理对应的hook
+		i := l.started[len(l.started)-1]
+		if l.hook(i).OnStop == nil {
 			continue
 		}
-		l.logger.Printf("STOP\t\t%s()", hook.caller)
-		if err := hook.OnStop(ctx); err != nil {
+		if err := l.runOnStop(ctx, i); err != nil {
 			// For best-effort cleanup, keep going after errors.
 			errs = append(errs, err)
 		}
 	}
 	return multierr.Combine(errs...)  // 输出所有stop失败的hook产生的error
 }
+
+// stopParallel stops hooks in waves, one stage at a time.
+func (l *Lifecycle) stopParallel(ctx context.Context) error {
+	var errs []error
+	for len(l.started) > 0 {
+		// Hooks were started one stage at a time, so the hooks of the last
+		// stage to start are at the end.
+		n := len(l.started) - 1
+		stage := l.hook(l.started[n]).Stage
+		for n > 0 && l.hook(l.started[n-1]).Stage == stage {
+			n--
+		}
+		errs = append(errs, l.stopWaves(ctx, l.started[n:]))
+		l.started = l.started[:n]
+	}
+	return multierr.Combine(errs...)
+}
+
+func (l *Lifecycle) stopWaves(ctx context.Context, hooks []int) error {
+	var errs []error
+	ws := waves(l.dependencies(), hooks)
+	for w := len(ws) - 1; w >= 0; w-- {
+		wave := ws[w]
+		waveErrs := make([]error, len(wave))
+		var wg sync.WaitGroup
+		for j, i := range wave {
+			if l.hook(i).OnStop == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(j, i int) {
+				defer wg.Done()
+				waveErrs[j] = l.runOnStop(ctx, i)
+			}(j, i)
+		}
+		wg.Wait()
+
+		// For best-effort cleanup, keep going after errors.
+		errs = append(errs, waveErrs...)
+	}
+	return multierr.Combine(errs...)
+}
+
+func (l *Lifecycle) runOnStart(ctx context.Context, i int) error {
+	hook := l.hook(i)
+	fname := hook.OnStartName
+	if fname == "" {
+		fname = fxreflect.FuncName(hook.OnStart)
+	}
+	l.log(&fxevent.OnStartExecuting{
+		FunctionName: fname,
+		CallerName:   hook.caller,
+	})
+
+	rh := l.begin(fname, hook.caller)
+	err := l.runHook(ctx, "OnStart", fname, hook.caller, hook.OnStartTimeout, hook.OnStart)
+	rec := l.end(rh, &l.startRecords, err)
+	l.log(&fxevent.OnStartExecuted{
+		FunctionName: fname,
+		CallerName:   hook.caller,
+		Runtime:      rec.Runtime,
+		Err:          err,
+	})
+	l.warnIfSlow("OnStart", rec)
+	return err
+}
+
+func (l *Lifecycle) runOnStop(ctx context.Context, i int) error {
+	hook := l.hook(i)
+	fname := hook.OnStopName
+	if fname == "" {
+		fname = fxreflect.FuncName(hook.OnStop)
+	}
+	l.log(&fxevent.OnStopExecuting{
+		FunctionName: fname,
+		CallerName:   hook.caller,
+	})
+
+	rh := l.begin(fname, hook.caller)
+	err := l.runHook(ctx, "OnStop", fname, hook.caller, hook.OnStopTimeout, hook.OnStop)
+	rec := l.end(rh, &l.stopRecords, err)
+	l.log(&fxevent.OnStopExecuted{
+		FunctionName: fname,
+		CallerName:   hook.caller,
+		Runtime:      rec.Runtime,
+		Err:          err,
+	})
+	l.warnIfSlow("OnStop", rec)
+	return err
+}
+
+func (l *Lifecycle) runOnDrain(ctx context.Context, i int) error {
+	hook := l.hook(i)
+	fname := fxreflect.FuncName(hook.OnDrain)
+	l.log(&fxevent.OnDrainExecuting{
+		FunctionName: fname,
+		CallerName:   hook.caller,
+	})
+
+	var records []HookRecord
+	rh := l.begin(fname, hook.caller)
+	err := l.runHook(ctx, "OnDrain", fname, hook.caller, 0, hook.OnDrain)
+	rec := l.end(rh, &records, err)
+	l.log(&fxevent.OnDrainExecuted{
+		FunctionName: fname,
+		CallerName:   hook.caller,
+		Runtime:      rec.Runtime,
+		Err:          err,
+	})
+	l.warnIfSlow("OnDrain", rec)
+	return err
+}
+
+// dependencies returns the dependencies of every hook, filling in those
+// missing from the deps function with all hooks before them.
+func (l *Lifecycle) dependencies() [][]int {
+	deps := l.deps()
+	out := make([][]int, l.numHooks())
+	for i := range out {
+		if i < len(deps) {
+			out[i] = deps[i]
+			continue
+		}
+		for j := 0; j < i; j++ {
+			out[i] = append(out[i], j)
+		}
+	}
+	return out
+}
+
+// waves groups the given hooks so that every hook comes in a later wave than
+// all of its dependencies. Dependencies outside the given hooks are ignored,
+// as are dependencies on later hooks, which keeps the graph acyclic.
+func waves(deps [][]int, hooks []int) [][]int {
+	in := make(map[int]bool, len(hooks))
+	for _, i := range hooks {
+		in[i] = true
+	}
+
+	sorted := append([]int(nil), hooks...)
+	sort.Ints(sorted)
+
+	level := make(map[int]int, len(hooks))
+	var ws [][]int
+	for _, i := range sorted {
+		lvl := 0
+		for _, d := range deps[i] {
+			if d < i && in[d] && level[d]+1 > lvl {
+				lvl = level[d] + 1
+			}
+		}
+		level[i] = lvl
+		for len(ws) <= lvl {
+			ws = append(ws, nil)
+		}
+		ws[lvl] = append(ws[lvl], i)
+	}
+	return ws
+}
+
+// StartRecords returns the OnStart hooks run by the most recent call to
+// Start, in the order they finished.
+func (l *Lifecycle) StartRecords() []HookRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]HookRecord(nil), l.startRecords...)
+}
+
+// StopRecords returns the OnStop hooks run by the most recent call to Stop,
+// in the order they finished.
+func (l *Lifecycle) StopRecords() []HookRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]HookRecord(nil), l.stopRecords...)
+}
+
+// Running reports the hook that is currently executing, with its runtime so
+// far. This is the hook to blame when Start or Stop hits its deadline. If
+// several hooks are running concurrently, the one that began first is
+// reported.
+// 返回当前正在执行的hook，用于定位超时的hook
+func (l *Lifecycle) Running() (HookRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var oldest *runningHook
+	for rh := range l.running {
+		if oldest == nil || rh.since.Before(oldest.since) {
+			oldest = rh
+		}
+	}
+	if oldest == nil {
+		return HookRecord{}, false
+	}
+	rec := oldest.rec
+	rec.Runtime = time.Since(oldest.since)
+	return rec, true
+}
+
+// runHook calls a hook with a context derived from ctx. If the hook has its
+// own timeout, the context expires after it and the hook is abandoned if it
+// runs any longer. Errors caused by an expired context name the hook.
+// 每个hook使用独立派生的context；设置了超时的hook在超时后不再等待其返回
+func (l *Lifecycle) runHook(ctx context.Context, kind, fname, caller string, timeout time.Duration, fn func(context.Context) error) error {
+	hookCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		hookCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var err error
+	if timeout <= 0 {
+		err = l.callHook(hookCtx, fname, caller, fn)
+	} else {
+		c := make(chan error, 1)
+		go func() { c <- l.callHook(hookCtx, fname, caller, fn) }()
+
+		select {
+		case err = <-c:
+		case <-hookCtx.Done():
+			// Prefer the hook's own result if it finished at the same time.
+			select {
+			case err = <-c:
+			default:
+				err = hookCtx.Err()
+			}
+		}
+	}
+
+	switch {
+	case err == nil || hookCtx.Err() == nil:
+		return err
+	case ctx.Err() == nil:
+		return fmt.Errorf("%v hook for %v exceeded its %v timeout: %w", kind, caller, timeout, err)
+	default:
+		return fmt.Errorf("%v hook added by %v failed: %w", kind, caller, err)
+	}
+}
+
+// callHook calls fn, turning a panic into an error if a panic handler was
+// set. The error names the hook fname, as reported in events.
+func (l *Lifecycle) callHook(ctx context.Context, fname, caller string, fn func(context.Context) error) (err error) {
+	if l.onPanic != nil {
+		defer func() {
+			if p := recover(); p != nil {
+				err = l.onPanic(fname, caller, p, debug.Stack())
+			}
+		}()
+	}
+	return fn(ctx)
+}
+
+func (l *Lifecycle) begin(fname, caller string) *runningHook {
+	rh := &runningHook{
+		rec:   HookRecord{CallerName: caller, FunctionName: fname},
+		since: time.Now(),
+	}
+	l.mu.Lock()
+	if l.running == nil {
+		l.running = make(map[*runningHook]struct{})
+	}
+	l.running[rh] = struct{}{}
+	l.mu.Unlock()
+	return rh
+}
+
+func (l *Lifecycle) end(rh *runningHook, records *[]HookRecord, err error) HookRecord {
+	rec := rh.rec
+	rec.Runtime = time.Since(rh.since)
+	rec.Err = err
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*records = append(*records, rec)
+	delete(l.running, rh)
+	return rec
+}
+
+func (l *Lifecycle) log(e fxevent.Event) {
+	l.logMu.Lock()
+	defer l.logMu.Unlock()
+	l.logger.LogEvent(e)
+}
+
+func (l *Lifecycle) warnIfSlow(hook string, rec HookRecord) {
+	if l.slowThreshold <= 0 || rec.Runtime <= l.slowThreshold {
+		return
+	}
+	l.log(&fxevent.SlowHook{
+		Hook:         hook,
+		FunctionName: rec.FunctionName,
+		CallerName:   rec.CallerName,
+		Runtime:      rec.Runtime,
+		Threshold:    l.slowThreshold,
+	})
+}
@@ -23,9 +23,11 @@ package lifecycle
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 
-	"go.uber.org/fx/internal/fxlog"
+	"go.uber.org/fx/fxevent"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/multierr"
@@ -104,7 +106,7 @@ func TestLifecycleStart(t *testing.T) {
 
 func TestLifecycleStop(t *testing.T) {
 	t.Run("DoesNothingWithoutHooks", func(t *testing.T) {
-		l := &Lifecycle{logger: fxlog.New()}
+		l := &Lifecycle{logger: fxevent.NopLogger}
 		assert.Nil(t, l.Stop(context.Background()), "no lifecycle hooks should have resulted in stop returning nil")
 	})
 
@@ -115,13 +117,13 @@ func TestLifecycleStop(t *testing.T) {
 				return nil
 			},
 		}
-		l := &Lifecycle{logger: fxlog.New()}
+		l := &Lifecycle{logger: fxevent.NopLogger}
 		l.Append(hook)
 		l.Stop(context.Background())
 	})
 
 	t.Run("ExecutesInReverseOrder", func(t *testing.T) {
-		l := &Lifecycle{logger: fxlog.New()}
+		l := &Lifecycle{logger: fxevent.NopLogger}
 		count := 2
 
 		l.Append(Hook{
@@ -213,3 +215,456 @@ func TestLifecycleStop(t *testing.T) {
 		l.Stop(context.Background())
 	})
 }
+
+type eventRecorder []fxevent.Event
+
+func (r *eventRecorder) LogEvent(e fxevent.Event) { *r = append(*r, e) }
+
+func TestLifecycleRecords(t *testing.T) {
+	t.Run("RecordsStartAndStop", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		err := errors.New("a starter error")
+		l.Append(Hook{
+			OnStart: func(context.Context) error { return nil },
+			OnStop:  func(context.Context) error { return nil },
+		})
+		l.Append(Hook{OnStart: func(context.Context) error { return err }})
+
+		assert.Equal(t, err, l.Start(context.Background()))
+		starts := l.StartRecords()
+		if assert.Len(t, starts, 2) {
+			assert.NoError(t, starts[0].Err)
+			assert.Equal(t, err, starts[1].Err)
+			assert.Contains(t, starts[1].CallerName, "TestLifecycleRecords")
+		}
+
+		assert.NoError(t, l.Stop(context.Background()))
+		assert.Len(t, l.StopRecords(), 1)
+	})
+
+	t.Run("NamesHookOnDeadline", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		l.Append(Hook{OnStart: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		err := l.Start(ctx)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "OnStart hook added by")
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		}
+	})
+
+	t.Run("Running", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		started, release := make(chan struct{}), make(chan struct{})
+		l.Append(Hook{OnStart: func(context.Context) error {
+			close(started)
+			<-release
+			return nil
+		}})
+
+		_, ok := l.Running()
+		assert.False(t, ok, "nothing should be running yet")
+
+		done := make(chan error)
+		go func() { done <- l.Start(context.Background()) }()
+		<-started
+		rec, ok := l.Running()
+		if assert.True(t, ok, "expected a running hook") {
+			assert.Contains(t, rec.CallerName, "TestLifecycleRecords")
+		}
+		close(release)
+		assert.NoError(t, <-done)
+
+		_, ok = l.Running()
+		assert.False(t, ok, "hook should have finished")
+	})
+
+	t.Run("WarnsAboutSlowHooks", func(t *testing.T) {
+		var events eventRecorder
+		l := New(&events)
+		l.SetSlowThreshold(time.Millisecond)
+		l.Append(Hook{
+			OnStart: func(context.Context) error { return nil },
+			OnStop: func(context.Context) error {
+				time.Sleep(5 * time.Millisecond)
+				return nil
+			},
+		})
+		assert.NoError(t, l.Start(context.Background()))
+		assert.NoError(t, l.Stop(context.Background()))
+
+		var slow []*fxevent.SlowHook
+		for _, e := range events {
+			if s, ok := e.(*fxevent.SlowHook); ok {
+				slow = append(slow, s)
+			}
+		}
+		if assert.Len(t, slow, 1) {
+			assert.Equal(t, "OnStop", slow[0].Hook)
+			assert.True(t, slow[0].Runtime > time.Millisecond)
+		}
+	})
+}
+
+func TestLifecycleHookTimeouts(t *testing.T) {
+	t.Run("OnStartTimeout", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		release := make(chan struct{})
+		defer close(release)
+		l.Append(Hook{
+			OnStart:        func(context.Context) error { <-release; return nil },
+			OnStartTimeout: 10 * time.Millisecond,
+		})
+
+		err := l.Start(context.Background())
+		if assert.Error(t, err) {
+			assert.Regexp(t, `OnStart hook for \S+TestLifecycleHookTimeouts\S* exceeded its 10ms timeout`, err.Error())
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		}
+	})
+
+	t.Run("DerivedContext", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		var deadline time.Time
+		l.Append(Hook{
+			OnStart: func(ctx context.Context) error {
+				var ok bool
+				deadline, ok = ctx.Deadline()
+				assert.True(t, ok, "hook context should have a deadline")
+				return nil
+			},
+			OnStartTimeout: time.Minute,
+		})
+		l.Append(Hook{
+			OnStart: func(ctx context.Context) error {
+				_, ok := ctx.Deadline()
+				assert.False(t, ok, "other hooks shouldn't inherit the timeout")
+				return nil
+			},
+		})
+
+		assert.NoError(t, l.Start(context.Background()))
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	})
+
+	t.Run("OnStopTimeoutDoesntHaltChain", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		release := make(chan struct{})
+		defer close(release)
+		stopped := false
+		l.Append(Hook{OnStop: func(context.Context) error { stopped = true; return nil }})
+		l.Append(Hook{
+			OnStop:        func(ctx context.Context) error { <-release; return nil },
+			OnStopTimeout: time.Millisecond,
+		})
+
+		assert.NoError(t, l.Start(context.Background()))
+		err := l.Stop(context.Background())
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "exceeded its 1ms timeout")
+		}
+		assert.True(t, stopped, "remaining hooks should still run")
+	})
+}
+
+func TestLifecycleParallel(t *testing.T) {
+	t.Run("Waves", func(t *testing.T) {
+		deps := [][]int{nil, nil, {0}, {1, 2}, {3}}
+		assert.Equal(t, [][]int{{0, 1}, {2}, {3}, {4}}, waves(deps, []int{0, 1, 2, 3, 4}))
+		assert.Equal(t, [][]int{{0, 1}, {3}}, waves(deps, []int{3, 1, 0}))
+	})
+
+	t.Run("MissingDependenciesAreSerial", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		l.SetParallel(func() [][]int { return [][]int{nil} })
+		var order []int
+		for i := 0; i < 3; i++ {
+			i := i
+			l.Append(Hook{OnStart: func(context.Context) error {
+				order = append(order, i)
+				return nil
+			}})
+		}
+		assert.NoError(t, l.Start(context.Background()))
+		assert.Equal(t, []int{0, 1, 2}, order)
+		assert.NoError(t, l.Stop(context.Background()))
+	})
+
+	t.Run("StopsEveryStartedHook", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		l.SetParallel(func() [][]int { return [][]int{nil, nil, {0, 1}} })
+
+		var (
+			mu      sync.Mutex
+			stopped []int
+		)
+		stop := func(i int) func(context.Context) error {
+			return func(context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				stopped = append(stopped, i)
+				return nil
+			}
+		}
+		l.Append(Hook{OnStop: stop(0)})
+		l.Append(Hook{
+			OnStart: func(context.Context) error { return errors.New("a starter error") },
+			OnStop:  stop(1),
+		})
+		l.Append(Hook{OnStart: func(context.Context) error {
+			t.Error("hook depending on a failed hook must not start")
+			return nil
+		}, OnStop: stop(2)})
+
+		assert.Error(t, l.Start(context.Background()))
+		assert.NoError(t, l.Stop(context.Background()))
+		assert.Equal(t, []int{0}, stopped)
+	})
+}
+
+func TestLifecycleAppendDuringStart(t *testing.T) {
+	// record returns a hook that appends its name to events when started
+	// and stopped.
+	newRecorder := func() (*[]string, func(string) Hook) {
+		var (
+			mu     sync.Mutex
+			events []string
+		)
+		add := func(e string) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, e)
+		}
+		return &events, func(name string) Hook {
+			return Hook{
+				OnStart: func(context.Context) error { add("start " + name); return nil },
+				OnStop:  func(context.Context) error { add("stop " + name); return nil },
+			}
+		}
+	}
+
+	t.Run("Serial", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		events, record := newRecorder()
+
+		l.Append(Hook{
+			OnStart: func(ctx context.Context) error {
+				l.Append(record("inner"))
+				return nil
+			},
+		})
+		l.Append(record("outer"))
+
+		assert.NoError(t, l.Start(context.Background()))
+		assert.NoError(t, l.Stop(context.Background()))
+		assert.Equal(t, []string{
+			"start outer", "start inner",
+			"stop inner", "stop outer",
+		}, *events)
+	})
+
+	t.Run("Parallel", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		l.SetParallel(func() [][]int { return nil })
+		events, record := newRecorder()
+
+		l.Append(Hook{
+			OnStart: func(ctx context.Context) error {
+				l.Append(record("inner"))
+				return nil
+			},
+		})
+		l.Append(record("outer"))
+
+		assert.NoError(t, l.Start(context.Background()))
+		assert.NoError(t, l.Stop(context.Background()))
+		assert.Equal(t, []string{
+			"start outer", "start inner",
+			"stop inner", "stop outer",
+		}, *events)
+	})
+
+	t.Run("AppendStartedDuringStart", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		events, record := newRecorder()
+
+		l.Append(Hook{
+			OnStart: func(ctx context.Context) error {
+				return l.AppendStarted(ctx, record("inner"))
+			},
+		})
+
+		assert.NoError(t, l.Start(context.Background()))
+		assert.NoError(t, l.Stop(context.Background()))
+		assert.Equal(t, []string{"start inner", "stop inner"}, *events)
+	})
+}
+
+func TestLifecycleAppendStarted(t *testing.T) {
+	t.Run("BeforeStart", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		started := false
+		assert.NoError(t, l.AppendStarted(context.Background(), Hook{
+			OnStart: func(context.Context) error { started = true; return nil },
+		}))
+		assert.False(t, started, "hook must not start before the lifecycle")
+
+		assert.NoError(t, l.Start(context.Background()))
+		assert.True(t, started)
+		assert.NoError(t, l.Stop(context.Background()))
+	})
+
+	t.Run("AfterStart", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		var events []string
+		l.Append(Hook{
+			OnStop: func(context.Context) error { events = append(events, "stop first"); return nil },
+		})
+		assert.NoError(t, l.Start(context.Background()))
+
+		assert.NoError(t, l.AppendStarted(context.Background(), Hook{
+			OnStart: func(context.Context) error { events = append(events, "start lazy"); return nil },
+			OnStop:  func(context.Context) error { events = append(events, "stop lazy"); return nil },
+		}))
+		assert.Equal(t, []string{"start lazy"}, events)
+
+		assert.NoError(t, l.Stop(context.Background()))
+		assert.Equal(t, []string{"start lazy", "stop lazy", "stop first"}, events)
+	})
+
+	t.Run("FailedStartIsNotStopped", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		assert.NoError(t, l.Start(context.Background()))
+
+		err := l.AppendStarted(context.Background(), Hook{
+			OnStart: func(context.Context) error { return errors.New("a starter error") },
+			OnStop: func(context.Context) error {
+				t.Error("hook that failed to start must not be stopped")
+				return nil
+			},
+		})
+		assert.EqualError(t, err, "a starter error")
+		assert.NoError(t, l.Stop(context.Background()))
+	})
+
+	t.Run("DuringStop", func(t *testing.T) {
+		l := New(fxevent.NopLogger)
+		l.Append(Hook{
+			OnStop: func(ctx context.Context) error {
+				return l.AppendStarted(ctx, Hook{
+					OnStart: func(context.Context) error {
+						t.Error("hook must not start while stopping")
+						return nil
+					},
+				})
+			},
+		})
+		assert.NoError(t, l.Start(context.Background()))
+		assert.EqualError(t, l.Stop(context.Background()),
+			"can't start a hook while the lifecycle is stopping")
+	})
+}
+
+func TestLifecycleDrain(t *testing.T) {
+	l := New(fxevent.NopLogger)
+	var drained []int
+	drain := func(i int, err error) func(context.Context) error {
+		return func(context.Context) error {
+			drained = append(drained, i)
+			return err
+		}
+	}
+	l.Append(Hook{OnDrain: drain(0, nil)})
+	l.Append(Hook{OnDrain: drain(1, errors.New("a drain error"))})
+	l.Append(Hook{
+		OnStart: func(context.Context) error { return errors.New("a starter error") },
+		OnDrain: drain(2, nil),
+	})
+
+	assert.False(t, l.HasDrainHooks())
+	assert.Error(t, l.Start(context.Background()))
+	assert.True(t, l.HasDrainHooks())
+
+	assert.EqualError(t, l.Drain(context.Background()), "a drain error")
+	assert.Equal(t, []int{1, 0}, drained, "only started hooks drain, in reverse order")
+	assert.NoError(t, l.Stop(context.Background()))
+}
+
+func TestLifecycleStages(t *testing.T) {
+	type recorder struct {
+		mu     sync.Mutex
+		events []string
+	}
+	hook := func(r *recorder, name string, stage int, err error) Hook {
+		add := func(e string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.events = append(r.events, e)
+		}
+		return Hook{
+			Stage:   stage,
+			OnStart: func(context.Context) error { add("start " + name); return err },
+			OnStop:  func(context.Context) error { add("stop " + name); return nil },
+		}
+	}
+
+	for _, parallel := range []bool{false, true} {
+		newLifecycle := func() *Lifecycle {
+			l := New(fxevent.NopLogger)
+			if parallel {
+				// Nothing depends on anything: only stages order hooks.
+				l.SetParallel(func() [][]int { return make([][]int, 8) })
+			}
+			return l
+		}
+		name := "Serial"
+		if parallel {
+			name = "Parallel"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			t.Run("InOrder", func(t *testing.T) {
+				l, r := newLifecycle(), &recorder{}
+				l.Append(hook(r, "serve", 2, nil))
+				l.Append(hook(r, "config", 0, nil))
+				l.Append(hook(r, "store", 1, nil))
+
+				assert.NoError(t, l.Start(context.Background()))
+				assert.NoError(t, l.Stop(context.Background()))
+				assert.Equal(t, []string{
+					"start config", "start store", "start serve",
+					"stop serve", "stop store", "stop config",
+				}, r.events)
+			})
+
+			t.Run("RollsBackStartedStages", func(t *testing.T) {
+				l, r := newLifecycle(), &recorder{}
+				l.Append(hook(r, "config", 0, nil))
+				l.Append(hook(r, "store", 1, errors.New("a starter error")))
+				l.Append(hook(r, "serve", 2, nil))
+
+				assert.Error(t, l.Start(context.Background()))
+				assert.NoError(t, l.Stop(context.Background()))
+				assert.Equal(t, []string{"start config", "start store", "stop config"}, r.events)
+			})
+		})
+	}
+
+	t.Run("AppendedForEarlierStage", func(t *testing.T) {
+		l, r := New(fxevent.NopLogger), &recorder{}
+		l.Append(Hook{Stage: 1, OnStart: func(context.Context) error {
+			l.Append(hook(r, "late", 0, nil))
+			return nil
+		}})
+		l.Append(hook(r, "next", 2, nil))
+
+		assert.NoError(t, l.Start(context.Background()))
+		assert.Equal(t, []string{"start late", "start next"}, r.events)
+		assert.NoError(t, l.Stop(context.Background()))
+	})
+}
@@ -22,6 +22,9 @@ package fx
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"fx-master/internal/lifecycle"
 )
@@ -29,24 +32,186 @@ import (
 // Lifecycle allows constructors to register callbacks that are executed on
 // application start and stop. See the documentation for App for details on Fx
 // applications' initialization, startup, and shutdown logic.
+//
+// Hooks appended by an OnStart hook while the application is starting run
+// in the same start, after the hooks appended before them, and are stopped
+// like any other hook. To register hooks for a component created after the
+// application has started, use AppendStarted.
 type Lifecycle interface {
 	Append(Hook)
 }
 
+// AppendStarted appends a hook for a component created lazily, possibly
+// after the application has started. If the application is running, the
+// hook's OnStart is called right away with the given context, and if it
+// succeeds, the hook's OnStop runs when the application stops, before the
+// hooks appended earlier. If the application hasn't started yet, the hook
+// is simply appended. AppendStarted fails if the application is stopping.
+//
+//   func (p *Pool) Get(ctx context.Context, name string) (*Conn, error) {
+//     c := newConn(name)
+//     err := fx.AppendStarted(ctx, p.lc, fx.Hook{
+//       OnStart: c.Open,
+//       OnStop:  c.Close,
+//     })
+//     return c, err
+//   }
+//
+// Lifecycles other than the one provided by Fx and fxtest don't know about
+// the application's state, so the hook is just appended to them.
+//
+// 应用启动后才创建的组件用AppendStarted注册hook：立即执行OnStart 应用停止时执行OnStop
+func AppendStarted(ctx context.Context, lc Lifecycle, h Hook) error {
+	if s, ok := lc.(interface {
+		AppendStarted(context.Context, Hook) error
+	}); ok {
+		return s.AppendStarted(ctx, h)
+	}
+	lc.Append(h)
+	return nil
+}
+
 // A Hook is a pair of start and stop callbacks, either of which can be nil.
 // If a Hook's OnStart callback isn't executed (because a previous OnStart
 // failure short-circuited application startup), its OnStop callback won't be
 // executed.
+//
+// By default, every hook shares the context passed to App.Start or App.Stop.
+// OnStartTimeout and OnStopTimeout give a hook a shorter deadline of its own:
+// the hook's context expires after that long, and if the hook hasn't returned
+// by then, the application stops waiting for it and fails with an error
+// naming the hook.
+//
+// OnDrain is called when the application is about to stop, after it stopped
+// being ready but before any OnStop hook runs, to let the component finish
+// its in-flight work. See DrainPeriod.
+//
+// Stage names the stage the hook belongs to, one of those declared with
+// Stages. Hooks without a Stage belong to DefaultStage.
 type Hook struct {
 	OnStart func(context.Context) error
 	OnStop  func(context.Context) error
+	OnDrain func(context.Context) error
+	Stage   string
+
+	OnStartTimeout time.Duration
+	OnStopTimeout  time.Duration
+
+	// Names reported for hooks built by StartStopHook and friends.
+	onStartName string
+	onStopName  string
+	callerName  string
 }
 
-type lifecycleWrapper struct{ *lifecycle.Lifecycle }
+type lifecycleWrapper struct {
+	*lifecycle.Lifecycle
+
+	// Hooks may be appended by hooks running concurrently, or by lazily
+	// started components, so owners and pending are guarded by mu.
+	mu      sync.Mutex
+	owners  []*depNode // node that appended each hook, or nil if unknown
+	pending int        // number of hooks appended since the last claim
+
+	stages []string // declared with Stages, in order
+}
 
 func (l *lifecycleWrapper) Append(h Hook) {
-	l.Lifecycle.Append(lifecycle.Hook{
-		OnStart: h.OnStart,
-		OnStop:  h.OnStop,
-	})
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.own()
+	l.Lifecycle.Append(l.internal(h))
+}
+
+// AppendStarted implements fx.AppendStarted.
+func (l *lifecycleWrapper) AppendStarted(ctx context.Context, h Hook) error {
+	// The hook's OnStart may append other hooks, so mu can't be held while
+	// it runs. Hooks appended concurrently all have unknown owners, so it
+	// doesn't matter if they end up in a different order.
+	l.mu.Lock()
+	l.own()
+	l.mu.Unlock()
+	return l.Lifecycle.AppendStarted(ctx, l.internal(h))
+}
+
+// own records a hook about to be appended. Its owner is known once the
+// function that appended it returns.
+func (l *lifecycleWrapper) own() {
+	l.owners = append(l.owners, nil)
+	l.pending++
+}
+
+func (l *lifecycleWrapper) internal(h Hook) lifecycle.Hook {
+	hook := lifecycle.Hook{
+		OnStart:        h.OnStart,
+		OnStop:         h.OnStop,
+		OnDrain:        h.OnDrain,
+		OnStartTimeout: h.OnStartTimeout,
+		OnStopTimeout:  h.OnStopTimeout,
+		OnStartName:    h.onStartName,
+		OnStopName:     h.onStopName,
+		CallerName:     h.callerName,
+	}
+
+	stage, ok := l.stage(h.Stage)
+	if !ok {
+		// Hooks can't fail to be appended, so fail to start instead.
+		err := fmt.Errorf("unknown lifecycle stage %q", h.Stage)
+		hook.OnStart = func(context.Context) error { return err }
+	}
+	hook.Stage = stage
+	return hook
+}
+
+// HookReport describes a single run of an OnStart or OnStop hook.
+type HookReport struct {
+	// CallerName is the name of the function that appended the hook.
+	CallerName string
+
+	// FunctionName is the name of the hook function itself.
+	FunctionName string
+
+	// Runtime is how long the hook ran. For a hook that was still running
+	// when the context expired, it's how long the hook had run by then.
+	Runtime time.Duration
+
+	// Err is the error returned by the hook, if any. Hooks that were still
+	// running when the context expired report the context's error.
+	Err error
+}
+
+// LifecycleReport describes the most recent start or stop of an
+// application: each hook that ran, in order, and the overall outcome.
+type LifecycleReport struct {
+	Hooks   []HookReport
+	Runtime time.Duration
+	Err     error
+}
+
+// report builds a LifecycleReport from the given hook records. If ctx has
+// expired while a hook is still running, that hook is included in the report
+// and named in the returned error.
+func (l *lifecycleWrapper) report(
+	ctx context.Context,
+	phase string,
+	records []lifecycle.HookRecord,
+	begin time.Time,
+	err error,
+) (LifecycleReport, error) {
+	r := LifecycleReport{Hooks: make([]HookReport, 0, len(records)+1)}
+	for _, rec := range records {
+		r.Hooks = append(r.Hooks, HookReport(rec))
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil && err == ctxErr {
+		if rec, ok := l.Running(); ok {
+			rec.Err = ctxErr
+			r.Hooks = append(r.Hooks, HookReport(rec))
+			err = fmt.Errorf("%v hook added by %v did not finish after %v: %w",
+				phase, rec.CallerName, rec.Runtime, ctxErr)
+		}
+	}
+
+	r.Runtime = time.Since(begin)
+	r.Err = err
+	return r, err
 }
@@ -0,0 +1,225 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/dig"
+)
+
+// Module is a named group of options. It behaves like Options, but the
+// application remembers which module each constructor and invocation came
+// from: the module name is added to Fx's PROVIDE and INVOKE log lines, and
+// errors returned by a module's constructors or invocations name the module
+// that caused them.
+//
+// Modules may be nested. A nested module is identified by the names of all
+// its enclosing modules, separated by dots. For example,
+//
+//  var Module = fx.Module("server",
+//    fx.Provide(NewServer),
+//    fx.Module("http",
+//      fx.Provide(NewMux),
+//    ),
+//  )
+//
+// logs NewMux as provided by module "server.http".
+//
+// Each module has its own scope in the application's container. Values
+// provided inside a module are shared with the rest of the application,
+// unless they're provided with Private.
+//
+// Module与Options类似，都是将多个Option合并成一个Option；不同的是Module会记录下每个构造函数和invoke函数所属的模块，
+// 并在PROVIDE/INVOKE日志及对应的error中输出模块名称，便于定位出现问题的模块。Module可以嵌套，嵌套模块的名称以"."连接。
+func Module(name string, opts ...Option) Option {
+	return moduleOption{name: name, options: opts}
+}
+
+type moduleOption struct {
+	name    string
+	options []Option
+}
+
+func (o moduleOption) apply(app *App) {
+	parent := app.module
+	m := &module{
+		name:   o.name,
+		parent: parent,
+		scope:  parent.scope.Scope(o.name),
+	}
+	parent.modules = append(parent.modules, m)
+
+	app.module = m
+	for _, opt := range o.options {
+		opt.apply(app)
+	}
+	app.module = parent
+}
+
+func (o moduleOption) String() string {
+	items := make([]string, len(o.options))
+	for i, opt := range o.options {
+		items[i] = fmt.Sprint(opt)
+	}
+	if len(items) == 0 {
+		return fmt.Sprintf("fx.Module(%q)", o.name)
+	}
+	return fmt.Sprintf("fx.Module(%q, %s)", o.name, strings.Join(items, ", "))
+}
+
+// Private is an option that may be passed to Provide alongside constructors
+// to keep their results inside the enclosing fx.Module. Private values can
+// only be consumed by the module's own constructors and invocations, and by
+// those of modules nested inside it. For example,
+//
+//  fx.Module("db",
+//    fx.Provide(fx.Private, newConnectionPool),
+//    fx.Provide(NewStore),
+//  )
+//
+// lets NewStore depend on the pool, while the rest of the application only
+// sees the *Store. Other modules may provide their own, unrelated values of
+// the same type privately without colliding. Consuming a private value from
+// outside its module fails with an error that names the owning module.
+//
+// Private has no effect when used outside of an fx.Module.
+//
+// Private用于Provide中，表示该Provide提供的类型仅在其所属的Module(及子Module)内可见，不会暴露给整个App。
+var Private = privateOption{}
+
+type privateOption struct{}
+
+func (privateOption) String() string { return "fx.Private" }
+
+// container is implemented by both dig.Container and dig.Scope. The root
+// module uses the application's container directly, every fx.Module gets a
+// child scope of its parent module.
+type container interface {
+	Provide(interface{}, ...dig.ProvideOption) error
+	Invoke(interface{}, ...dig.InvokeOption) error
+	Decorate(interface{}, ...dig.DecorateOption) error
+	Scope(string, ...dig.ScopeOption) *dig.Scope
+}
+
+// module is a node in the tree of modules declared with fx.Module. The root
+// of the tree is unnamed and holds everything passed directly to fx.New.
+type module struct {
+	name    string
+	parent  *module
+	modules []*module
+	scope   container
+}
+
+// path returns the dot-separated names of this module and all its
+// ancestors, or an empty string for the root module.
+func (m *module) path() string {
+	if m == nil || m.parent == nil {
+		return ""
+	}
+	if p := m.parent.path(); p != "" {
+		return p + "." + m.name
+	}
+	return m.name
+}
+
+// wrapError attributes err to this module. Errors from the root module are
+// returned unchanged.
+func (m *module) wrapError(err error) error {
+	if err == nil || m.path() == "" {
+		return err
+	}
+	return moduleError{module: m.path(), err: err}
+}
+
+// moduleError is an error raised by a constructor or invocation that was
+// registered inside an fx.Module.
+type moduleError struct {
+	module string
+	err    error
+}
+
+func (e moduleError) Error() string {
+	return fmt.Sprintf("module %q: %v", e.module, e.err)
+}
+
+func (e moduleError) Unwrap() error { return e.err }
+
+// explainPrivate checks whether err, returned when invoking the given
+// function, was caused by a value that the function needs, directly or
+// through its dependencies, which is only provided privately inside a module
+// it can't see. If so, it returns an error naming that module; otherwise err
+// is returned as-is.
+func (app *App) explainPrivate(invoked *depNode, err error) error {
+	producers := producersByKey(app.nodesOfKind("constructor"))
+
+	seen := map[*depNode]bool{invoked: true}
+	var explain func(n *depNode) error
+	explain = func(n *depNode) error {
+		for _, param := range n.inputs {
+			var found bool
+			var hidden *depNode
+			for _, p := range producers[param.key] {
+				if !p.visibleTo(n.module) {
+					if hidden == nil {
+						hidden = p
+					}
+					continue
+				}
+				found = true
+				if !seen[p] {
+					seen[p] = true
+					if err := explain(p); err != nil {
+						return err
+					}
+				}
+			}
+
+			// Groups may be empty and optional values absent.
+			if !found && hidden != nil && !param.optional && param.key.group == "" {
+				return privateError{key: param.key.String(), module: hidden.module, err: err}
+			}
+		}
+		return nil
+	}
+	if perr := explain(invoked); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// privateError reports a missing dependency that is provided, but only
+// privately inside another module.
+type privateError struct {
+	key    string
+	module string
+	err    error
+}
+
+func (e privateError) Error() string {
+	return fmt.Sprintf("%v is private to module %q: %v", e.key, e.module, e.err)
+}
+
+func (e privateError) Unwrap() error { return e.err }
+
+var _typeOfError = reflect.TypeOf((*error)(nil)).Elem()
@@ -0,0 +1,304 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+type moduleLogSpy struct {
+	*bytes.Buffer
+}
+
+func (s moduleLogSpy) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(s.Buffer, format, args...)
+	s.Buffer.WriteRune('\n')
+}
+
+func TestModule(t *testing.T) {
+	type A struct{}
+	type B struct{}
+
+	t.Run("ProvidesAndInvokes", func(t *testing.T) {
+		var invoked bool
+		app := fxtest.New(t,
+			fx.Module("foo",
+				fx.Provide(func() A { return A{} }),
+				fx.Invoke(func(A) { invoked = true }),
+			),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.True(t, invoked, "module invoke wasn't run")
+	})
+
+	t.Run("ValuesAreSharedAcrossModules", func(t *testing.T) {
+		var a *A
+		app := fxtest.New(t,
+			fx.Module("foo",
+				fx.Provide(func() *A { return &A{} }),
+			),
+			fx.Module("bar",
+				fx.Invoke(func(x *A) { a = x }),
+			),
+			fx.Invoke(func(x *A) { assert.True(t, a == x, "expected a single *A") }),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.NotNil(t, a)
+	})
+
+	t.Run("LogsModuleNames", func(t *testing.T) {
+		spy := moduleLogSpy{&bytes.Buffer{}}
+		app := fx.New(
+			fx.Logger(spy),
+			fx.Module("server",
+				fx.Provide(func() A { return A{} }),
+				fx.Module("http",
+					fx.Provide(func(A) B { return B{} }),
+					fx.Invoke(func(B) {}),
+				),
+			),
+			fx.Invoke(func(A) {}),
+		)
+		require.NoError(t, app.Err())
+
+		out := spy.String()
+		assert.Contains(t, out, "PROVIDE\tfx_test.A <= go.uber.org/fx_test.TestModule.func3.1() from module \"server\"")
+		assert.Contains(t, out, "PROVIDE\tfx_test.B <= go.uber.org/fx_test.TestModule.func3.2() from module \"server.http\"")
+		assert.Contains(t, out, "INVOKE\t\tgo.uber.org/fx_test.TestModule.func3.3() from module \"server.http\"")
+		assert.Contains(t, out, "INVOKE\t\tgo.uber.org/fx_test.TestModule.func3.4()\n")
+		assert.NotContains(t, out, "fx.Lifecycle <= go.uber.org/fx.New.func1() from module")
+	})
+
+	t.Run("ProvideErrorNamesModule", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("outer",
+				fx.Module("inner",
+					fx.Provide(A{}),
+				),
+			),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `module "outer.inner": `)
+		assert.Contains(t, err.Error(), "must provide constructor function")
+	})
+
+	t.Run("InvokeErrorNamesModule", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("foo",
+				fx.Invoke(func() error { return errors.New("great sadness") }),
+			),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Equal(t, `module "foo": great sadness`, err.Error())
+	})
+
+	t.Run("MissingDependencyNamesModule", func(t *testing.T) {
+		var hookErr error
+		app := fx.New(
+			fx.NopLogger,
+			fx.ErrorHook(errHandlerFunc(func(err error) { hookErr = err })),
+			fx.Module("foo",
+				fx.Invoke(func(A) {}),
+			),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `module "foo": `)
+		assert.Contains(t, err.Error(), "fx_test.A")
+
+		require.Error(t, hookErr)
+		graph, vErr := fx.VisualizeError(hookErr)
+		require.NoError(t, vErr, "module errors should still be visualized")
+		assert.Contains(t, graph, "digraph")
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, `fx.Module("foo")`, fmt.Sprint(fx.Module("foo")))
+		assert.Equal(t,
+			`fx.Module("foo", fx.Module("bar"))`,
+			fmt.Sprint(fx.Module("foo", fx.Module("bar"))),
+		)
+	})
+}
+
+func TestPrivateProvide(t *testing.T) {
+	type A struct{ name string }
+	type B struct{ a *A }
+
+	t.Run("VisibleInsideModule", func(t *testing.T) {
+		var b *B
+		app := fxtest.New(t,
+			fx.Module("foo",
+				fx.Provide(fx.Private, func() *A { return &A{name: "foo"} }),
+				fx.Provide(func(a *A) *B { return &B{a: a} }),
+			),
+			fx.Populate(&b),
+		)
+		defer app.RequireStart().RequireStop()
+		require.NotNil(t, b)
+		assert.Equal(t, "foo", b.a.name)
+	})
+
+	t.Run("VisibleInNestedModules", func(t *testing.T) {
+		var a *A
+		app := fxtest.New(t,
+			fx.Module("foo",
+				fx.Provide(fx.Private, func() *A { return &A{name: "foo"} }),
+				fx.Module("bar",
+					fx.Invoke(func(x *A) { a = x }),
+				),
+			),
+		)
+		defer app.RequireStart().RequireStop()
+		require.NotNil(t, a)
+		assert.Equal(t, "foo", a.name)
+	})
+
+	t.Run("InvisibleOutsideModule", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("foo",
+				fx.Provide(fx.Private, func() *A { return &A{} }),
+			),
+			fx.Module("bar",
+				fx.Invoke(func(*A) {}),
+			),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `module "bar": *fx_test.A is private to module "foo"`)
+	})
+
+	t.Run("InvisibleToRoot", func(t *testing.T) {
+		type in struct {
+			fx.In
+
+			A *A `name:"a"`
+		}
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("foo",
+				fx.Provide(fx.Private, fx.Annotated{
+					Name:   "a",
+					Target: func() *A { return &A{} },
+				}),
+			),
+			fx.Invoke(func(in) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `*fx_test.A[name="a"] is private to module "foo"`)
+	})
+
+	t.Run("NamedAndGroupedInvisibleToRoot", func(t *testing.T) {
+		type in struct {
+			fx.In
+
+			A *A `name:"a"`
+		}
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("foo",
+				fx.Provide(fx.Private, fx.Annotated{
+					Name:   "a",
+					Group:  "as",
+					Target: func() *A { return &A{} },
+				}),
+			),
+			fx.Invoke(func(in) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `*fx_test.A[name="a"] is private to module "foo"`)
+	})
+
+	t.Run("TransitiveConsumerOutsideModule", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("foo",
+				fx.Provide(fx.Private, func() *A { return &A{} }),
+			),
+			fx.Provide(func(a *A) *B { return &B{a: a} }),
+			fx.Invoke(func(*B) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `*fx_test.A is private to module "foo"`)
+	})
+
+	t.Run("InvisibleToParentModule", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("foo",
+				fx.Module("db",
+					fx.Provide(fx.Private, func() *A { return &A{} }),
+				),
+				fx.Provide(func(a *A) *B { return &B{a: a} }),
+			),
+			fx.Invoke(func(*B) {}),
+		)
+		err := app.Err()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `*fx_test.A is private to module "foo.db"`)
+	})
+
+	t.Run("SiblingsDoNotCollide", func(t *testing.T) {
+		var names []string
+		app := fxtest.New(t,
+			fx.Module("foo",
+				fx.Provide(fx.Private, func() *A { return &A{name: "foo"} }),
+				fx.Invoke(func(a *A) { names = append(names, a.name) }),
+			),
+			fx.Module("bar",
+				fx.Provide(fx.Private, func() *A { return &A{name: "bar"} }),
+				fx.Invoke(func(a *A) { names = append(names, a.name) }),
+			),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"foo", "bar"}, names)
+	})
+
+	t.Run("PrivateAtRootIsPublic", func(t *testing.T) {
+		var a *A
+		app := fxtest.New(t,
+			fx.Provide(fx.Private, func() *A { return &A{} }),
+			fx.Module("foo", fx.Populate(&a)),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.NotNil(t, a)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Contains(t, fmt.Sprint(fx.Provide(fx.Private, bytes.NewBuffer)), "fx.Provide(fx.Private, bytes.NewBuffer())")
+	})
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"fx-master/internal/fxreflect"
+)
+
+// RecoverFromPanics is an Option that turns panics into errors. Panics in
+// constructors, decorators and invoked functions fail the application like
+// any other error returned during New, and are reported by Err and passed
+// to ErrorHook handlers. Panics in OnStart hooks fail Start, which rolls back
+// as usual, and panics in OnStop hooks are returned by Stop.
+//
+// These errors are PanicErrors, which hold the value passed to panic, the
+// stack of the panicking goroutine and the name of the function that
+// panicked.
+//
+// 将constructor、decorator、invoke及hook中的panic转换为error
+func RecoverFromPanics() Option {
+	return recoverFromPanicsOption{}
+}
+
+type recoverFromPanicsOption struct{}
+
+func (recoverFromPanicsOption) apply(app *App) {
+	app.recoverFromPanics = true
+	app.lifecycle.SetPanicHandler(func(fname, caller string, value interface{}, stack []byte) error {
+		return &PanicError{Func: fname, Caller: caller, Value: value, Stack: stack}
+	})
+}
+
+func (recoverFromPanicsOption) String() string { return "fx.RecoverFromPanics()" }
+
+// PanicError is returned in place of a panic when the RecoverFromPanics
+// option is used.
+type PanicError struct {
+	// Func is the name of the constructor, decorator, invoked function or
+	// lifecycle hook that panicked. Hooks are named as in lifecycle events,
+	// so a StartHook is named after the function passed to it.
+	Func string
+
+	// Caller is the name of the function that appended the hook, for panics
+	// in lifecycle hooks. It's empty otherwise.
+	Caller string
+
+	// Value is the value passed to panic.
+	Value interface{}
+
+	// Stack is the stack trace of the goroutine that panicked, taken when
+	// the panic was recovered.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e.Caller != "" {
+		return fmt.Sprintf("panic in hook %v added by %v: %v", e.Func, e.Caller, e.Value)
+	}
+	return fmt.Sprintf("panic in %v: %v", e.Func, e.Value)
+}
+
+// invoke calls fn through the given container. With RecoverFromPanics, a
+// panic in fn, or in any constructor or decorator that it needed, is
+// returned as a PanicError.
+func (app *App) invoke(c container, fn interface{}) (err error) {
+	if app.recoverFromPanics {
+		defer func() {
+			if p := recover(); p != nil {
+				err = &PanicError{
+					Func:  fxreflect.FuncName(app.panicking(fn)),
+					Value: p,
+					Stack: debug.Stack(),
+				}
+			}
+		}()
+	}
+	return c.Invoke(fn)
+}
+
+// panicking returns the function given to Fx that is panicking, by looking
+// for the innermost one on the stack. It must be called from a deferred
+// function while the panic is in progress. If none of them is on the stack,
+// the invoked function is blamed.
+func (app *App) panicking(invoked interface{}) interface{} {
+	funcs := make(map[string]interface{})
+	add := func(fn interface{}) {
+		// Annotated functions run the function they wrap.
+		fn = unwrapTarget(fn)
+		funcs[funcPCName(fn)] = fn
+	}
+	add(invoked)
+	for _, p := range app.provides {
+		add(p.target)
+	}
+	for _, d := range app.decorates {
+		add(d.target)
+	}
+	for _, i := range app.invokes {
+		add(i.target)
+	}
+
+	pcs := make([]uintptr, 256)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(1, pcs)])
+	panicked := false
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			panicked = true
+		} else if fn, ok := funcs[frame.Function]; ok && panicked {
+			return fn
+		}
+		if !more {
+			return invoked
+		}
+	}
+}
+
+// funcPCName returns the name the runtime uses for fn in stack traces.
+func funcPCName(fn interface{}) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return ""
+	}
+	// Method values are wrapped in a function ending with -fm.
+	return strings.TrimSuffix(runtime.FuncForPC(v.Pointer()).Name(), "-fm")
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+type panicA struct{}
+
+func newPanickingA() *panicA { panic("great sadness") }
+
+func panickingStart() { panic("great sadness") }
+
+func TestRecoverFromPanics(t *testing.T) {
+	t.Run("Constructor", func(t *testing.T) {
+		var handled error
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.ErrorHook(errHandlerFunc(func(err error) { handled = err })),
+			fx.Provide(newPanickingA),
+			fx.Invoke(func(*panicA) {}),
+		)
+
+		var pe *fx.PanicError
+		require.True(t, errors.As(app.Err(), &pe), "expected a PanicError, got %v", app.Err())
+		assert.Equal(t, "go.uber.org/fx_test.newPanickingA()", pe.Func)
+		assert.Equal(t, "great sadness", pe.Value)
+		assert.Contains(t, string(pe.Stack), "newPanickingA")
+		assert.Empty(t, pe.Caller)
+		assert.EqualError(t, pe, "panic in go.uber.org/fx_test.newPanickingA(): great sadness")
+		assert.Equal(t, app.Err(), handled, "error hook should receive the panic")
+	})
+
+	t.Run("AnnotatedConstructor", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.Provide(fx.Annotate(newPanickingA, fx.ResultTags(`name:"a"`))),
+			fx.Invoke(func(struct {
+				fx.In
+
+				A *panicA `name:"a"`
+			}) {
+			}),
+		)
+
+		var pe *fx.PanicError
+		require.True(t, errors.As(app.Err(), &pe), "expected a PanicError, got %v", app.Err())
+		assert.Equal(t, "go.uber.org/fx_test.newPanickingA()", pe.Func)
+	})
+
+	t.Run("Invoke", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func() { panic(errors.New("great sadness")) }),
+		)
+
+		var pe *fx.PanicError
+		require.True(t, errors.As(app.Err(), &pe))
+		assert.Regexp(t, `TestRecoverFromPanics\.func\d+\.1\(\)$`, pe.Func)
+		assert.EqualError(t, pe.Value.(error), "great sadness")
+	})
+
+	t.Run("AnnotatedInvoke", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.Supply(fx.Annotated{Name: "a", Target: &panicA{}}),
+			fx.Invoke(fx.Annotate(func(*panicA) { panic("great sadness") }, fx.ParamTags(`name:"a"`))),
+		)
+
+		var pe *fx.PanicError
+		require.True(t, errors.As(app.Err(), &pe), "expected a PanicError, got %v", app.Err())
+		assert.Regexp(t, `TestRecoverFromPanics\.func\d+\.1\(\)$`, pe.Func)
+	})
+
+	t.Run("Decorator", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.Provide(func() *panicA { return &panicA{} }),
+			fx.Decorate(func(*panicA) *panicA { panic("great sadness") }),
+			fx.Invoke(func(*panicA) {}),
+		)
+
+		var pe *fx.PanicError
+		require.True(t, errors.As(app.Err(), &pe))
+		assert.Regexp(t, `TestRecoverFromPanics\.func\d+\.2\(\)$`, pe.Func)
+	})
+
+	t.Run("OnStartRollsBack", func(t *testing.T) {
+		var stopped bool
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{OnStop: func(context.Context) error {
+					stopped = true
+					return nil
+				}})
+				lc.Append(fx.Hook{OnStart: func(context.Context) error {
+					panic("great sadness")
+				}})
+			}),
+		)
+		require.NoError(t, app.Err())
+
+		err := app.Start(context.Background())
+		var pe *fx.PanicError
+		require.True(t, errors.As(err, &pe), "expected a PanicError, got %v", err)
+		assert.Contains(t, pe.Caller, "TestRecoverFromPanics")
+		assert.Contains(t, pe.Error(), "panic in hook ")
+		assert.True(t, stopped, "start should have rolled back")
+	})
+
+	t.Run("StartHook", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.StartHook(panickingStart))
+			}),
+		)
+		require.NoError(t, app.Err())
+
+		var pe *fx.PanicError
+		require.True(t, errors.As(app.Start(context.Background()), &pe))
+		assert.Equal(t, "go.uber.org/fx_test.panickingStart()", pe.Func)
+	})
+
+	t.Run("HookWithTimeout", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.RecoverFromPanics(),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{
+					OnStop:        func(context.Context) error { panic("great sadness") },
+					OnStopTimeout: time.Second,
+				})
+			}),
+		)
+		require.NoError(t, app.Start(context.Background()))
+
+		var pe *fx.PanicError
+		assert.True(t, errors.As(app.Stop(context.Background()), &pe))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		assert.Panics(t, func() {
+			fx.New(fx.NopLogger, fx.Invoke(func() { panic("great sadness") }))
+		})
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+// ParallelLifecycle is an Option that runs independent lifecycle hooks
+// concurrently.
+//
+// By default, OnStart hooks run one at a time in the order they were
+// appended, and OnStop hooks in the reverse order. With ParallelLifecycle,
+// Fx looks at the constructor, decorator or invoked function that appended
+// each hook and at the values it consumes and produces. A hook's OnStart
+// runs as soon as the OnStart hooks appended by everything it depends on,
+// directly or not, have succeeded, alongside any other hooks that are ready.
+// OnStop hooks run in the reverse order: a hook is stopped before any of its
+// dependencies.
+//
+// Hooks appended by the same function keep their relative order. Hooks
+// appended anywhere else are treated as depending on every hook appended
+// before them, and every hook appended after them depends on them.
+//
+// If an OnStart hook fails, hooks that were running alongside it are allowed
+// to finish, and all hooks that started successfully are stopped.
+//
+// 开启后没有依赖关系的hook会并发执行OnStart/OnStop
+var ParallelLifecycle Option = parallelLifecycleOption{}
+
+type parallelLifecycleOption struct{}
+
+func (parallelLifecycleOption) apply(app *App) {
+	app.lifecycle.SetParallel(func() [][]int {
+		return app.lifecycle.hookDeps(app.nodes)
+	})
+}
+
+func (parallelLifecycleOption) String() string { return "fx.ParallelLifecycle" }
+
+// claim attributes every hook appended since the last claim to the given
+// node. Dig calls a function only once everything it depends on has been
+// built, so hooks appended between two claims belong to the function that
+// finished last.
+func (l *lifecycleWrapper) claim(n *depNode) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.owners) - l.pending; i < len(l.owners); i++ {
+		l.owners[i] = n
+	}
+	l.pending = 0
+}
+
+// hookDeps returns, for each hook, the indices of the earlier hooks it
+// depends on.
+func (l *lifecycleWrapper) hookDeps(nodes []*depNode) [][]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	producers := producersByKey(nodes)
+
+	// Everything each owner depends on, directly or not.
+	reach := make(map[*depNode]map[*depNode]bool)
+	var visit func(n *depNode, seen map[*depNode]bool)
+	visit = func(n *depNode, seen map[*depNode]bool) {
+		for _, param := range n.inputs {
+			for _, p := range producers[param.key] {
+				if p != n && !seen[p] {
+					seen[p] = true
+					visit(p, seen)
+				}
+			}
+		}
+	}
+
+	deps := make([][]int, len(l.owners))
+	for i, owner := range l.owners {
+		if owner != nil && reach[owner] == nil {
+			reach[owner] = make(map[*depNode]bool)
+			visit(owner, reach[owner])
+		}
+		for j := 0; j < i; j++ {
+			other := l.owners[j]
+			if owner == nil || other == nil || owner == other || reach[owner][other] {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	return deps
+}
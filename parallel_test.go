@@ -0,0 +1,223 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+// hookLog records the order in which hooks run.
+type hookLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *hookLog) add(e string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+func (l *hookLog) index(e string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, got := range l.events {
+		if got == e {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestParallelLifecycle(t *testing.T) {
+	type A struct{}
+	type B struct{}
+	type C struct{}
+
+	t.Run("IndependentHooksRunConcurrently", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		// Each hook waits for the other to start, which only works if they
+		// run at the same time.
+		rendezvous := func(context.Context) error {
+			wg.Done()
+			done := make(chan struct{})
+			go func() { wg.Wait(); close(done) }()
+			select {
+			case <-done:
+				return nil
+			case <-time.After(time.Second):
+				return errors.New("hooks didn't run concurrently")
+			}
+		}
+
+		app := fxtest.New(t,
+			fx.ParallelLifecycle,
+			fx.Provide(
+				func(lc fx.Lifecycle) *A {
+					lc.Append(fx.Hook{OnStart: rendezvous})
+					return &A{}
+				},
+				func(lc fx.Lifecycle) *B {
+					lc.Append(fx.Hook{OnStart: rendezvous})
+					return &B{}
+				},
+			),
+			fx.Invoke(func(*A, *B) {}),
+		)
+		app.RequireStart().RequireStop()
+	})
+
+	t.Run("DependenciesStartFirstAndStopLast", func(t *testing.T) {
+		var log hookLog
+		hook := func(lc fx.Lifecycle, name string) {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					log.add("start " + name)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					log.add("stop " + name)
+					return nil
+				},
+			})
+		}
+
+		app := fxtest.New(t,
+			fx.ParallelLifecycle,
+			fx.Provide(
+				func(lc fx.Lifecycle) *A {
+					hook(lc, "A")
+					return &A{}
+				},
+				// B doesn't need a hook of its own to link C to A.
+				func(*A) *B { return &B{} },
+				func(lc fx.Lifecycle, _ *B) *C {
+					hook(lc, "C")
+					return &C{}
+				},
+			),
+			fx.Invoke(func(lc fx.Lifecycle, _ *C) { hook(lc, "invoke") }),
+		)
+		app.RequireStart().RequireStop()
+
+		assert.True(t, log.index("start A") < log.index("start C"), "A must start before C: %v", log.events)
+		assert.True(t, log.index("start C") < log.index("start invoke"), "C must start before invoke: %v", log.events)
+		assert.True(t, log.index("stop invoke") < log.index("stop C"), "invoke must stop before C: %v", log.events)
+		assert.True(t, log.index("stop C") < log.index("stop A"), "C must stop before A: %v", log.events)
+	})
+
+	t.Run("ValueGroups", func(t *testing.T) {
+		type Server struct{}
+		type handlers struct {
+			fx.In
+
+			Handlers []string `group:"handlers"`
+		}
+
+		var log hookLog
+		app := fxtest.New(t,
+			fx.ParallelLifecycle,
+			fx.Provide(
+				fx.Annotated{
+					Group: "handlers",
+					Target: func(lc fx.Lifecycle) string {
+						lc.Append(fx.Hook{OnStart: func(context.Context) error {
+							time.Sleep(5 * time.Millisecond)
+							log.add("handler")
+							return nil
+						}})
+						return "handler"
+					},
+				},
+				func(lc fx.Lifecycle, _ handlers) *Server {
+					lc.Append(fx.Hook{OnStart: func(context.Context) error {
+						log.add("server")
+						return nil
+					}})
+					return &Server{}
+				},
+			),
+			fx.Invoke(func(*Server) {}),
+		)
+		app.RequireStart().RequireStop()
+		assert.Equal(t, []string{"handler", "server"}, log.events)
+	})
+
+	t.Run("RollsBackStartedHooks", func(t *testing.T) {
+		var log hookLog
+		app := fx.New(
+			fx.NopLogger,
+			fx.ParallelLifecycle,
+			fx.Provide(
+				func(lc fx.Lifecycle) *A {
+					lc.Append(fx.Hook{
+						OnStart: func(context.Context) error { return nil },
+						OnStop: func(context.Context) error {
+							log.add("stop A")
+							return nil
+						},
+					})
+					return &A{}
+				},
+				func(lc fx.Lifecycle) *B {
+					lc.Append(fx.Hook{OnStart: func(context.Context) error {
+						return errors.New("great sadness")
+					}})
+					return &B{}
+				},
+				func(lc fx.Lifecycle, _ *A, _ *B) *C {
+					lc.Append(fx.Hook{
+						OnStart: func(context.Context) error {
+							log.add("start C")
+							return nil
+						},
+						OnStop: func(context.Context) error {
+							log.add("stop C")
+							return nil
+						},
+					})
+					return &C{}
+				},
+			),
+			fx.Invoke(func(*C) {}),
+		)
+		require.NoError(t, app.Err())
+
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "great sadness")
+		assert.Equal(t, []string{"stop A"}, log.events)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "fx.ParallelLifecycle", fx.ParallelLifecycle.(interface{ String() string }).String())
+	})
+}
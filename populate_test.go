@@ -207,7 +207,7 @@ func TestPopulateErrors(t *testing.T) {
 		{
 			msg:     "container pointer without fx.In",
 			opt:     Populate(&containerNoIn{}),
-			wantErr: "is not in the container",
+			wantErr: "missing type:",
 		},
 		{
 			msg:     "function",
@@ -217,7 +217,7 @@ func TestPopulateErrors(t *testing.T) {
 		{
 			msg:     "function pointer",
 			opt:     Populate(&fn),
-			wantErr: "is not in the container",
+			wantErr: "missing type:",
 		},
 		{
 			msg:     "invalid last argument",
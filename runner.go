@@ -0,0 +1,280 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fx-master/fxevent"
+	"fx-master/internal/fxreflect"
+)
+
+// Runner runs long-running tasks, like network servers or queue consumers,
+// for as long as the application runs. Runner is provided to all Fx
+// applications.
+//
+// Each task runs in its own goroutine, started by an OnStart hook at the
+// point Go was called, as if it had been appended to the Lifecycle. When the
+// application stops, the task's context is canceled and Stop waits for the
+// task to return, up to the StopTimeout. Tasks added after the application
+// has started begin right away.
+//
+// If a task returns an error before its context is canceled, the
+// application shuts down through the Shutdowner, with the error as the
+// ShutdownReason and an exit code of 1. Tasks returning nil simply end.
+// Supervise runs workers that are restarted instead.
+//
+//   func NewServer(r fx.Runner, srv *http.Server) error {
+//     return r.Go("http", func(ctx context.Context) error {
+//       go func() {
+//         <-ctx.Done()
+//         srv.Close()
+//       }()
+//       if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+//         return err
+//       }
+//       return nil
+//     })
+//   }
+//
+// 后台任务管理：随app启动运行，停止时取消context并等待其退出；任务失败时以该错误关闭app
+type Runner interface {
+	// Go runs the task in a goroutine for as long as the application runs.
+	// It fails if the application is stopping.
+	Go(name string, task func(context.Context) error) error
+
+	// Supervise runs the worker like Go, restarting it according to its
+	// RestartPolicy.
+	Supervise(Worker) error
+}
+
+// RestartPolicy decides whether a supervised worker that returned before
+// the application stopped is run again.
+type RestartPolicy int
+
+const (
+	// RestartNever never restarts the worker. If it fails, the application
+	// shuts down, like it does for tasks passed to Runner.Go.
+	RestartNever RestartPolicy = iota
+
+	// RestartOnFailure restarts the worker when it returns an error. Workers
+	// returning nil simply end.
+	RestartOnFailure
+
+	// RestartAlways restarts the worker whenever it returns.
+	RestartAlways
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+	return fmt.Sprintf("RestartPolicy(%d)", int(p))
+}
+
+// Defaults for the zero fields of a Worker.
+const (
+	DefaultRestartBackoff    = 100 * time.Millisecond
+	DefaultMaxRestartBackoff = 30 * time.Second
+	DefaultMaxRestarts       = 5
+	DefaultRestartWindow     = time.Minute
+)
+
+// A Worker is a long-running task run by Runner.Supervise.
+//
+// When a worker is restarted, it first waits for Backoff, doubled after
+// every restart within the Window, up to MaxBackoff. Workers that need more
+// than MaxRestarts restarts within the Window are given up on, and the
+// application shuts down with an exit code of 1. Zero fields take the
+// default values above.
+//
+// 可按RestartPolicy自动重启的后台worker：指数退避，窗口期内重启次数过多时关闭app
+type Worker struct {
+	Name    string
+	Run     func(context.Context) error
+	Restart RestartPolicy
+
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	MaxRestarts int
+	Window      time.Duration
+}
+
+type runner struct {
+	app *App
+}
+
+func (r *runner) Go(name string, task func(context.Context) error) error {
+	return r.supervise(Worker{Name: name, Run: task}, fxreflect.Caller())
+}
+
+func (r *runner) Supervise(w Worker) error {
+	return r.supervise(w, fxreflect.Caller())
+}
+
+func (r *runner) supervise(w Worker, caller string) error {
+	if w.Backoff <= 0 {
+		w.Backoff = DefaultRestartBackoff
+	}
+	if w.MaxBackoff <= 0 {
+		w.MaxBackoff = DefaultMaxRestartBackoff
+	}
+	if w.MaxRestarts <= 0 {
+		w.MaxRestarts = DefaultMaxRestarts
+	}
+	if w.Window <= 0 {
+		w.Window = DefaultRestartWindow
+	}
+
+	t := &runningWorker{app: r.app, Worker: w}
+	fname := fxreflect.FuncName(w.Run)
+	return r.app.lifecycle.AppendStarted(context.Background(), Hook{
+		OnStart:     t.start,
+		OnStop:      t.stop,
+		onStartName: fname,
+		onStopName:  fname,
+		callerName:  caller,
+	})
+}
+
+// runningWorker is a worker passed to Runner.Supervise or Runner.Go.
+type runningWorker struct {
+	Worker
+	app *App
+
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the worker is done for good
+	err    error         // returned by Run after it was canceled
+}
+
+func (t *runningWorker) start(context.Context) error {
+	// The worker outlives the context of the OnStart hook.
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.done = make(chan struct{})
+	t.err = nil
+
+	go func() {
+		defer close(t.done)
+		t.supervise(ctx)
+	}()
+	return nil
+}
+
+// supervise runs the worker until the context is canceled, the worker ends
+// for good, or the application has to shut down because of it.
+func (t *runningWorker) supervise(ctx context.Context) {
+	var restarts []time.Time // within the window
+	for {
+		begin := time.Now()
+		err := t.Run(ctx)
+		if ctx.Err() != nil {
+			// Stopped by the application: report any error from Stop.
+			if err != nil && !errors.Is(err, context.Canceled) {
+				t.err = err
+			}
+			return
+		}
+		t.app.logger.LogEvent(&fxevent.WorkerExited{
+			WorkerName: t.Name,
+			Runtime:    time.Since(begin),
+			Err:        err,
+		})
+
+		if !t.shouldRestart(err) {
+			if err != nil {
+				t.shutdown(fmt.Errorf("worker %q failed: %w", t.Name, err))
+			}
+			return
+		}
+
+		now := time.Now()
+		for len(restarts) > 0 && now.Sub(restarts[0]) > t.Window {
+			restarts = restarts[1:]
+		}
+		if len(restarts) >= t.MaxRestarts {
+			reason := fmt.Errorf("worker %q restarted %d times within %v, giving up",
+				t.Name, len(restarts), t.Window)
+			if err != nil {
+				reason = fmt.Errorf("%v: %w", reason, err)
+			}
+			t.shutdown(reason)
+			return
+		}
+		restarts = append(restarts, now)
+
+		delay := t.Backoff
+		for i := 1; i < len(restarts) && delay < t.MaxBackoff; i++ {
+			delay *= 2
+		}
+		if delay > t.MaxBackoff {
+			delay = t.MaxBackoff
+		}
+		t.app.logger.LogEvent(&fxevent.WorkerRestarting{
+			WorkerName: t.Name,
+			Restarts:   len(restarts),
+			Delay:      delay,
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (t *runningWorker) shouldRestart(err error) bool {
+	switch t.Restart {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	}
+	return false
+}
+
+func (t *runningWorker) shutdown(reason error) {
+	t.app.shutdowner().Shutdown(ShutdownReason(reason), ExitCode(1))
+}
+
+func (t *runningWorker) stop(ctx context.Context) error {
+	t.cancel()
+	select {
+	case <-t.done:
+		if t.err != nil {
+			return fmt.Errorf("worker %q failed: %w", t.Name, t.err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker %q didn't return: %w", t.Name, ctx.Err())
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestRunner(t *testing.T) {
+	t.Run("CanceledOnStop", func(t *testing.T) {
+		running := make(chan struct{})
+		app := fxtest.New(t, fx.Invoke(func(r fx.Runner) error {
+			return r.Go("loop", func(ctx context.Context) error {
+				close(running)
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		}))
+		app.RequireStart()
+		<-running
+		app.RequireStop()
+	})
+
+	t.Run("FailureShutsDown", func(t *testing.T) {
+		app := fxtest.New(t, fx.Invoke(func(r fx.Runner) error {
+			return r.Go("server", func(context.Context) error {
+				return errors.New("address already in use")
+			})
+		}))
+		wait := app.Wait()
+		app.RequireStart()
+		defer app.RequireStop()
+
+		select {
+		case sig := <-wait:
+			assert.Equal(t, 1, sig.ExitCode)
+			require.Error(t, sig.Reason)
+			assert.Equal(t, `worker "server" failed: address already in use`, sig.Reason.Error())
+		case <-time.After(time.Second):
+			t.Fatal("failed task didn't shut the application down")
+		}
+	})
+
+	t.Run("StopTimeout", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+		app := fxtest.New(t, fx.Invoke(func(r fx.Runner) error {
+			return r.Go("stuck", func(context.Context) error {
+				<-block
+				return nil
+			})
+		}))
+		app.RequireStart()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := app.Stop(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "context deadline exceeded")
+	})
+
+	t.Run("ErrorAfterCancel", func(t *testing.T) {
+		app := fxtest.New(t, fx.Invoke(func(r fx.Runner) error {
+			return r.Go("flush", func(ctx context.Context) error {
+				<-ctx.Done()
+				return errors.New("couldn't flush")
+			})
+		}))
+		app.RequireStart()
+		err := app.Stop(context.Background())
+		assert.EqualError(t, err, `worker "flush" failed: couldn't flush`)
+	})
+
+	t.Run("AfterStart", func(t *testing.T) {
+		var r fx.Runner
+		app := fxtest.New(t, fx.Populate(&r))
+		app.RequireStart()
+
+		ran := make(chan struct{})
+		require.NoError(t, r.Go("lazy", func(ctx context.Context) error {
+			close(ran)
+			<-ctx.Done()
+			return nil
+		}))
+		<-ran
+		app.RequireStop()
+	})
+}
+
+func TestSupervise(t *testing.T) {
+	// attempts returns a worker function that fails the first n times and
+	// then blocks until it's canceled, sending each attempt to c.
+	attempts := func(n int, c chan<- int) func(context.Context) error {
+		i := 0
+		return func(ctx context.Context) error {
+			i++
+			c <- i
+			if i <= n {
+				return errors.New("great sadness")
+			}
+			<-ctx.Done()
+			return nil
+		}
+	}
+
+	t.Run("RestartOnFailure", func(t *testing.T) {
+		spy := &eventSpy{}
+		runs := make(chan int, 10)
+		app := fx.New(
+			fx.WithLogger(func() fxevent.Logger { return spy }),
+			fx.Invoke(func(r fx.Runner) error {
+				return r.Supervise(fx.Worker{
+					Name:    "consumer",
+					Run:     attempts(2, runs),
+					Restart: fx.RestartOnFailure,
+					Backoff: time.Millisecond,
+				})
+			}),
+		)
+		require.NoError(t, app.Start(context.Background()))
+		for want := 1; want <= 3; want++ {
+			assert.Equal(t, want, <-runs)
+		}
+		require.NoError(t, app.Stop(context.Background()))
+
+		var delays []time.Duration
+		for _, e := range spy.events {
+			if e, ok := e.(*fxevent.WorkerRestarting); ok {
+				assert.Equal(t, "consumer", e.WorkerName)
+				delays = append(delays, e.Delay)
+			}
+		}
+		assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, delays,
+			"backoff must double")
+		assert.True(t, spy.has(&fxevent.WorkerExited{}))
+	})
+
+	t.Run("OnFailureEndsOnSuccess", func(t *testing.T) {
+		runs := 0
+		app := fxtest.New(t, fx.Invoke(func(r fx.Runner) error {
+			return r.Supervise(fx.Worker{
+				Name:    "migrate",
+				Run:     func(context.Context) error { runs++; return nil },
+				Restart: fx.RestartOnFailure,
+			})
+		}))
+		app.RequireStart().RequireStop()
+		assert.Equal(t, 1, runs)
+	})
+
+	t.Run("RestartAlways", func(t *testing.T) {
+		runs := make(chan int, 10)
+		app := fxtest.New(t, fx.Invoke(func(r fx.Runner) error {
+			i := 0
+			return r.Supervise(fx.Worker{
+				Name: "poller",
+				Run: func(ctx context.Context) error {
+					i++
+					runs <- i
+					if i < 3 {
+						return nil
+					}
+					<-ctx.Done()
+					return nil
+				},
+				Restart: fx.RestartAlways,
+				Backoff: time.Millisecond,
+			})
+		}))
+		app.RequireStart()
+		assert.Equal(t, 1, <-runs)
+		assert.Equal(t, 2, <-runs)
+		assert.Equal(t, 3, <-runs)
+		app.RequireStop()
+	})
+
+	t.Run("TooManyRestarts", func(t *testing.T) {
+		runs := make(chan int, 10)
+		app := fxtest.New(t, fx.Invoke(func(r fx.Runner) error {
+			return r.Supervise(fx.Worker{
+				Name:        "flaky",
+				Run:         attempts(10, runs),
+				Restart:     fx.RestartOnFailure,
+				Backoff:     time.Millisecond,
+				MaxRestarts: 2,
+			})
+		}))
+		wait := app.Wait()
+		app.RequireStart()
+		defer app.RequireStop()
+
+		select {
+		case sig := <-wait:
+			assert.Equal(t, 1, sig.ExitCode)
+			assert.EqualError(t, sig.Reason,
+				`worker "flaky" restarted 2 times within 1m0s, giving up: great sadness`)
+		case <-time.After(time.Second):
+			t.Fatal("worker wasn't given up on")
+		}
+		assert.Len(t, runs, 3, "must run once and restart twice")
+	})
+
+	t.Run("RestartPolicyString", func(t *testing.T) {
+		assert.Equal(t, "never", fx.RestartNever.String())
+		assert.Equal(t, "on-failure", fx.RestartOnFailure.String())
+		assert.Equal(t, "always", fx.RestartAlways.String())
+		assert.Equal(t, "RestartPolicy(7)", fx.RestartPolicy(7).String())
+	})
+}
@@ -23,7 +23,9 @@ package fx
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"syscall"
+	"time"
 )
 
 // 提供了手动触发application的shutdown，发送一个signal信号给所有处于open的Done-channel
@@ -37,22 +39,123 @@ type Shutdowner interface {
 }
 
 // 提供shutdowm相关处理的配置属性
-// 注意：当前没有option被实现
 // ShutdownOption provides a way to configure properties of the shutdown
-// process. Currently, no options have been implemented.
+// process.
 type ShutdownOption interface {
 	apply(*shutdowner)
 }
 
+type exitCodeOption int
+
+func (code exitCodeOption) apply(s *shutdowner) {
+	s.exitCode = int(code)
+	s.exitCodeSet = true
+}
+
+// ExitCode is a ShutdownOption that sets the exit code reported by Wait and
+// used by Run to exit the process.
+//
+// 设置进程退出码：通过Wait获取，Run会以该退出码结束进程
+func ExitCode(code int) ShutdownOption {
+	return exitCodeOption(code)
+}
+
+type shutdownReasonOption struct{ err error }
+
+func (o shutdownReasonOption) apply(s *shutdowner) {
+	s.reason = o.err
+}
+
+// ShutdownReason is a ShutdownOption that records why the application is
+// shutting down. The reason is reported by Wait. Unless ExitCode is also
+// given, a shutdown with a non-nil reason exits with code 1.
+//
+// 记录app关闭的原因
+func ShutdownReason(err error) ShutdownOption {
+	return shutdownReasonOption{err}
+}
+
+type shutdownTimeoutOption time.Duration
+
+func (d shutdownTimeoutOption) apply(s *shutdowner) {
+	s.timeout = time.Duration(d)
+}
+
+// ShutdownTimeout is a ShutdownOption that makes Shutdown block until the
+// application has fully stopped, or rolled back a failed start, or until the
+// timeout passes. Shutdown returns an error if the application didn't stop
+// in time.
+//
+// Since the application can't stop while it's starting, don't use this
+// option from inside an OnStart hook.
+//
+// Shutdown默认发送信号后立即返回；设置该选项后会阻塞直到app停止或超时
+func ShutdownTimeout(d time.Duration) ShutdownOption {
+	return shutdownTimeoutOption(d)
+}
+
+// ShutdownSignal describes why an application was asked to stop: the signal
+// that was received, the exit code the process should end with, and, for
+// shutdowns triggered through the Shutdowner, the reason given.
+type ShutdownSignal struct {
+	Signal   os.Signal
+	ExitCode int
+	Reason   error
+}
+
+// String returns the signal's name.
+func (sig ShutdownSignal) String() string {
+	return sig.Signal.String()
+}
+
 type shutdowner struct {
 	app *App
+
+	exitCode    int
+	exitCodeSet bool
+	reason      error
+	timeout     time.Duration
 }
 
 // 广播一个signal给到application所有的Done channel并开始停止
-// Shutdown broadcasts a signal to all of the application's Done channels
-// and begins the Stop process.
+// Shutdown broadcasts a signal to all of the application's Done and Wait
+// channels and begins the Stop process. If no one is listening yet, the
+// shutdown is recorded and the next Done or Wait channel receives it at
+// once.
 func (s *shutdowner) Shutdown(opts ...ShutdownOption) error {
-	return s.app.broadcastSignal(syscall.SIGTERM)
+	sd := shutdowner{app: s.app}
+	for _, opt := range opts {
+		opt.apply(&sd)
+	}
+
+	code := sd.exitCode
+	if !sd.exitCodeSet && sd.reason != nil {
+		code = 1
+	}
+
+	// Grab the channel before broadcasting so that we can't miss the Stop
+	// that follows.
+	s.app.donesMu.RLock()
+	stopped := s.app.stopped
+	s.app.donesMu.RUnlock()
+
+	err := s.app.broadcastSignal(ShutdownSignal{
+		Signal:   syscall.SIGTERM,
+		ExitCode: code,
+		Reason:   sd.reason,
+	})
+	if err != nil || sd.timeout <= 0 {
+		return err
+	}
+
+	timer := time.NewTimer(sd.timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("application didn't stop within %v of shutdown", sd.timeout)
+	}
 }
 
 func (app *App) shutdowner() Shutdowner {
@@ -60,26 +163,108 @@ func (app *App) shutdowner() Shutdowner {
 }
 
 // 广播signal
-func (app *App) broadcastSignal(signal os.Signal) error {
-	app.donesMu.RLock()
-	defer app.donesMu.RUnlock()
+func (app *App) broadcastSignal(sig ShutdownSignal) error {
+	app.donesMu.Lock()
+	defer app.donesMu.Unlock()
+
+	// Done and Wait channels created later receive this signal right away.
+	app.pendingShutdown = &sig
+	app.health.unready()
 
 	var unsent int
 	for _, done := range app.dones {
 		select {
-		case done <- signal:
+		case done <- sig.Signal:
 		default:
 			// shutdown called when done channel has already received a
 			// termination signal that has not been cleared
 			unsent++
 		}
 	}
+	for _, wait := range app.waits {
+		select {
+		case wait <- sig:
+		default:
+			unsent++
+		}
+	}
 
 	if unsent != 0 {
 		return fmt.Errorf("failed to send %v signal to %v out of %v channels",
-			signal, unsent, len(app.dones),
+			sig.Signal, unsent, len(app.dones)+len(app.waits),
 		)
 	}
 
 	return nil
 }
+
+// relaySignals forwards the signals that end the application to its Wait
+// channels, and marks the application unready, until the application is
+// stopped. It's started by the first call to Done or Wait, with donesMu held,
+// so it only sees signals the application already listens for.
+func (app *App) relaySignals() {
+	if app.signalRelay != nil {
+		return
+	}
+
+	if len(app.signals) == 0 {
+		return
+	}
+
+	c := make(chan os.Signal, 1)
+	quit := make(chan struct{})
+	app.signalRelay = quit
+	signal.Notify(c, app.signals...)
+
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case sig := <-c:
+				app.health.unready()
+				app.broadcastWait(ShutdownSignal{Signal: sig})
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// resumeRelay relays signals again after a restart if the application has
+// Done or Wait channels listening for them.
+func (app *App) resumeRelay() {
+	app.donesMu.Lock()
+	defer app.donesMu.Unlock()
+	if len(app.dones)+len(app.waits) > 0 {
+		app.relaySignals()
+	}
+}
+
+// broadcastWait sends a signal received from the OS to every Wait channel.
+// Done channels receive such signals directly.
+func (app *App) broadcastWait(sig ShutdownSignal) {
+	app.donesMu.RLock()
+	defer app.donesMu.RUnlock()
+	for _, wait := range app.waits {
+		select {
+		case wait <- sig:
+		default:
+		}
+	}
+}
+
+// markStopped is called once the application has stopped. It stops relaying
+// signals, forgets any pending shutdown and releases Shutdown calls waiting
+// for the application to stop.
+func (app *App) markStopped() {
+	app.donesMu.Lock()
+	defer app.donesMu.Unlock()
+	if app.signalRelay != nil {
+		close(app.signalRelay)
+		app.signalRelay = nil
+	}
+	app.signalHandlers.stop()
+	app.pendingShutdown = nil
+	close(app.stopped)
+	app.stopped = make(chan struct{})
+}
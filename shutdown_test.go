@@ -21,10 +21,14 @@
 package fx_test
 
 import (
+	"context"
+	"errors"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxtest"
 )
@@ -45,6 +49,134 @@ func TestShutdown(t *testing.T) {
 		assert.Equal(t, syscall.SIGTERM, <-done2, "done channel 2 did not receive signal")
 	})
 
+	t.Run("Wait", func(t *testing.T) {
+		var s fx.Shutdowner
+		app := fxtest.New(
+			t,
+			fx.Populate(&s),
+		)
+
+		wait, done := app.Wait(), app.Done()
+		defer app.RequireStart().RequireStop()
+
+		reason := errors.New("job finished")
+		assert.NoError(t, s.Shutdown(fx.ExitCode(2), fx.ShutdownReason(reason)))
+		assert.Equal(t, fx.ShutdownSignal{
+			Signal:   syscall.SIGTERM,
+			ExitCode: 2,
+			Reason:   reason,
+		}, <-wait)
+		assert.Equal(t, syscall.SIGTERM, <-done, "done channel did not receive signal")
+	})
+
+	t.Run("ReasonDefaultsToFailure", func(t *testing.T) {
+		var s fx.Shutdowner
+		app := fxtest.New(
+			t,
+			fx.Populate(&s),
+		)
+
+		wait := app.Wait()
+		defer app.RequireStart().RequireStop()
+
+		assert.NoError(t, s.Shutdown(fx.ShutdownReason(errors.New("great sadness"))))
+		sig := <-wait
+		assert.Equal(t, 1, sig.ExitCode)
+		assert.Equal(t, "terminated", sig.String())
+	})
+
+	t.Run("RecordedForLaterChannels", func(t *testing.T) {
+		var s fx.Shutdowner
+		app := fxtest.New(
+			t,
+			fx.Populate(&s),
+		)
+		defer app.RequireStart().RequireStop()
+
+		assert.NoError(t, s.Shutdown(fx.ExitCode(4)), "error in app shutdown")
+		assert.Equal(t, syscall.SIGTERM, <-app.Done(), "done channel did not receive signal")
+		assert.Equal(t, 4, (<-app.Wait()).ExitCode, "wait channel did not receive signal")
+	})
+
+	t.Run("ForgottenAfterStop", func(t *testing.T) {
+		var s fx.Shutdowner
+		app := fxtest.New(
+			t,
+			fx.Populate(&s),
+		)
+		app.RequireStart()
+		assert.NoError(t, s.Shutdown())
+		app.RequireStop()
+
+		select {
+		case sig := <-app.Wait():
+			t.Fatalf("unexpected signal %v after the app stopped", sig)
+		default:
+		}
+	})
+
+	t.Run("TimeoutWaitsForStop", func(t *testing.T) {
+		var s fx.Shutdowner
+		app := fxtest.New(
+			t,
+			fx.Populate(&s),
+		)
+		app.RequireStart()
+
+		wait := app.Wait()
+		var stopped bool
+		go func() {
+			<-wait
+			time.Sleep(10 * time.Millisecond)
+			stopped = true
+			app.RequireStop()
+		}()
+
+		assert.NoError(t, s.Shutdown(fx.ShutdownTimeout(time.Second)))
+		assert.True(t, stopped, "Shutdown returned before the app stopped")
+	})
+
+	t.Run("TimeoutEndsWhenStartFails", func(t *testing.T) {
+		var (
+			s    fx.Shutdowner
+			wait <-chan fx.ShutdownSignal
+		)
+		app := fx.New(
+			fx.NopLogger,
+			fx.Populate(&s),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				lc.Append(fx.Hook{OnStart: func(context.Context) error {
+					<-wait
+					return errors.New("great sadness")
+				}})
+			}),
+		)
+		wait = app.Wait()
+
+		shutdownErr := make(chan error, 1)
+		go func() { shutdownErr <- s.Shutdown(fx.ShutdownTimeout(time.Minute)) }()
+		require.Error(t, app.Start(context.Background()))
+
+		select {
+		case err := <-shutdownErr:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("Shutdown still waiting after the app failed to start")
+		}
+	})
+
+	t.Run("TimeoutExpires", func(t *testing.T) {
+		var s fx.Shutdowner
+		app := fxtest.New(
+			t,
+			fx.Populate(&s),
+		)
+		defer app.RequireStart().RequireStop()
+
+		err := s.Shutdown(fx.ShutdownTimeout(time.Millisecond))
+		assert.EqualError(t, err, "application didn't stop within 1ms of shutdown")
+	})
+
 	t.Run("ErrorOnUnsentSignal", func(t *testing.T) {
 		var s fx.Shutdowner
 		app := fxtest.New(
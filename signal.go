@@ -0,0 +1,159 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// _defaultSignals end the application unless the Signals option says
+// otherwise.
+var _defaultSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+
+// Signals is an Option that sets the signals that end the application. Done
+// and Wait channels receive these signals, and Run stops the application
+// when it gets one. By default, SIGINT and SIGTERM end the application.
+//
+// Passing no signals leaves the Shutdowner as the only way to end the
+// application. To react to other signals without ending the application, use
+// SignalHandlers.
+//
+// 设置哪些信号会结束app，默认为SIGINT和SIGTERM
+func Signals(sigs ...os.Signal) Option {
+	return signalsOption(sigs)
+}
+
+type signalsOption []os.Signal
+
+func (o signalsOption) apply(app *App) {
+	app.signals = append([]os.Signal{}, o...)
+}
+
+func (o signalsOption) String() string {
+	items := make([]string, len(o))
+	for i, sig := range o {
+		items[i] = fmt.Sprint(sig)
+	}
+	return fmt.Sprintf("fx.Signals(%s)", strings.Join(items, ", "))
+}
+
+// A SignalHandler is called whenever the process receives one of its signals
+// while the application is running. Handlers don't end the application.
+// Handlers with no Signals are never called.
+type SignalHandler struct {
+	Signals []os.Signal
+	Handle  func(os.Signal)
+}
+
+// SignalHandlers lets constructors react to signals without ending the
+// application, for example to reload configuration on SIGHUP. Handlers are
+// only called between a successful Start and the following Stop, one at a
+// time, in the order they were appended. Handlers appended while the
+// application is running are called for the signals received after they
+// were appended. SignalHandlers is provided to all Fx applications.
+//
+// 在app运行期间处理不会结束app的信号，例如SIGHUP时重新加载配置
+type SignalHandlers interface {
+	Append(SignalHandler)
+}
+
+type signalHandlers struct {
+	mu       sync.Mutex
+	handlers []SignalHandler
+	c        chan os.Signal // non-nil while handlers are being called
+	quit     chan struct{}
+}
+
+func (h *signalHandlers) Append(handler SignalHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers = append(h.handlers, handler)
+	if h.c != nil {
+		notify(h.c, handler.Signals)
+	}
+}
+
+// start begins calling handlers for their signals until stop is called.
+func (h *signalHandlers) start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.c != nil {
+		return
+	}
+
+	c := make(chan os.Signal, 1)
+	for _, handler := range h.handlers {
+		notify(c, handler.Signals)
+	}
+
+	quit := make(chan struct{})
+	h.c, h.quit = c, quit
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case sig := <-c:
+				h.handle(sig)
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// notify relays sigs to c, in addition to the signals already relayed to it.
+func notify(c chan os.Signal, sigs []os.Signal) {
+	if len(sigs) == 0 {
+		// Notify with no signals would relay every signal, disabling their
+		// default actions.
+		return
+	}
+	signal.Notify(c, sigs...)
+}
+
+func (h *signalHandlers) handle(sig os.Signal) {
+	h.mu.Lock()
+	handlers := h.handlers
+	h.mu.Unlock()
+
+	for _, handler := range handlers {
+		for _, s := range handler.Signals {
+			if s == sig {
+				handler.Handle(sig)
+				break
+			}
+		}
+	}
+}
+
+func (h *signalHandlers) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.c != nil {
+		close(h.quit)
+		h.c, h.quit = nil, nil
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestSignals(t *testing.T) {
+	t.Run("EndsApp", func(t *testing.T) {
+		app := fxtest.New(t, fx.Signals(syscall.SIGUSR1))
+		done, wait := app.Done(), app.Wait()
+		app.RequireStart()
+		defer app.RequireStop()
+
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+		assert.Equal(t, syscall.SIGUSR1, <-done)
+		assert.Equal(t, syscall.SIGUSR1, (<-wait).Signal)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "fx.Signals(interrupt, hangup)",
+			fx.Signals(syscall.SIGINT, syscall.SIGHUP).(interface{ String() string }).String())
+	})
+}
+
+func TestSignalHandlers(t *testing.T) {
+	handled := make(chan os.Signal, 1)
+	app := fxtest.New(t, fx.Invoke(func(h fx.SignalHandlers) {
+		h.Append(fx.SignalHandler{
+			Signals: []os.Signal{syscall.SIGUSR2},
+			Handle:  func(sig os.Signal) { handled <- sig },
+		})
+	}))
+	done := app.Done()
+	app.RequireStart()
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR2))
+	select {
+	case sig := <-handled:
+		assert.Equal(t, syscall.SIGUSR2, sig)
+	case <-time.After(time.Second):
+		t.Fatal("signal handler wasn't called")
+	}
+
+	select {
+	case sig := <-done:
+		t.Fatalf("handled signal ended the app: %v", sig)
+	default:
+	}
+	app.RequireStop()
+}
+
+func TestSignalHandlersAppendedWhileRunning(t *testing.T) {
+	var handlers fx.SignalHandlers
+	app := fxtest.New(t, fx.Populate(&handlers))
+	app.RequireStart()
+	defer app.RequireStop()
+
+	handled := make(chan os.Signal, 1)
+	handlers.Append(fx.SignalHandler{
+		Signals: []os.Signal{syscall.SIGWINCH},
+		Handle:  func(sig os.Signal) { handled <- sig },
+	})
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGWINCH))
+	select {
+	case sig := <-handled:
+		assert.Equal(t, syscall.SIGWINCH, sig)
+	case <-time.After(time.Second):
+		t.Fatal("signal handler appended after start wasn't called")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+// DefaultStage is the stage of hooks that don't name one. Unless it's
+// declared with Stages, it comes after all declared stages.
+const DefaultStage = "default"
+
+// Stages is an Option that declares named lifecycle stages, in the order
+// they start. Hooks join a stage by naming it in Hook.Stage.
+//
+// Start runs the OnStart hooks of one stage at a time: every hook of a stage
+// must succeed before any hook of the next stage starts. Within a stage,
+// hooks start in the order they were appended, or concurrently with
+// ParallelLifecycle. Stop runs the stages in reverse, and if Start fails,
+// only the hooks of the stages that started are stopped.
+//
+//   fx.New(
+//     fx.Stages("config", "stores", "caches", fx.DefaultStage),
+//     ...
+//   )
+//
+//   lc.Append(fx.Hook{Stage: "stores", OnStart: db.Open, OnStop: db.Close})
+//
+// Stages may be given several times; later stages come after earlier ones.
+// Declaring a stage twice is an error, and hooks naming a stage that wasn't
+// declared fail to start.
+//
+// 声明有序的生命周期阶段：Start逐个阶段启动hook，Stop逆序停止
+func Stages(names ...string) Option {
+	return stagesOption(names)
+}
+
+type stagesOption []string
+
+func (o stagesOption) apply(app *App) {
+	for _, name := range o {
+		switch {
+		case name == "":
+			app.err = multierr.Append(app.err, errors.New("lifecycle stages must have a name"))
+			continue
+		case app.lifecycle.declared(name):
+			app.err = multierr.Append(app.err, fmt.Errorf("lifecycle stage %q declared twice", name))
+			continue
+		}
+		app.lifecycle.stages = append(app.lifecycle.stages, name)
+	}
+}
+
+func (o stagesOption) String() string {
+	return fmt.Sprintf("fx.Stages(%s)", strings.Join(o, ", "))
+}
+
+// stage returns the position of the named stage.
+func (l *lifecycleWrapper) stage(name string) (int, bool) {
+	if name == "" {
+		name = DefaultStage
+	}
+	for i, s := range l.stages {
+		if s == name {
+			return i, true
+		}
+	}
+	if name == DefaultStage {
+		return len(l.stages), true
+	}
+	return len(l.stages), false
+}
+
+// declared reports whether the stage was declared with Stages.
+func (l *lifecycleWrapper) declared(name string) bool {
+	for _, s := range l.stages {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestStages(t *testing.T) {
+	// staged appends a hook recording its start and stop to events.
+	staged := func(lc fx.Lifecycle, events *[]string, name, stage string) {
+		lc.Append(fx.Hook{
+			Stage:   stage,
+			OnStart: func(context.Context) error { *events = append(*events, "start "+name); return nil },
+			OnStop:  func(context.Context) error { *events = append(*events, "stop "+name); return nil },
+		})
+	}
+
+	t.Run("DefaultStageLast", func(t *testing.T) {
+		var events []string
+		app := fxtest.New(t,
+			fx.Stages("config", "stores"),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				staged(lc, &events, "server", "")
+				staged(lc, &events, "db", "stores")
+				staged(lc, &events, "flags", "config")
+			}),
+		)
+		app.RequireStart().RequireStop()
+		assert.Equal(t, []string{
+			"start flags", "start db", "start server",
+			"stop server", "stop db", "stop flags",
+		}, events)
+	})
+
+	t.Run("DeclaredDefaultStage", func(t *testing.T) {
+		var events []string
+		app := fxtest.New(t,
+			fx.Stages("config", fx.DefaultStage),
+			fx.Stages("serve"),
+			fx.Invoke(func(lc fx.Lifecycle) {
+				staged(lc, &events, "listener", "serve")
+				staged(lc, &events, "cache", "")
+				staged(lc, &events, "flags", "config")
+			}),
+		)
+		app.RequireStart().RequireStop()
+		assert.Equal(t, []string{
+			"start flags", "start cache", "start listener",
+			"stop listener", "stop cache", "stop flags",
+		}, events)
+	})
+
+	t.Run("UnknownStage", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{Stage: "warmup"})
+		}))
+		err := app.Start(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown lifecycle stage "warmup"`)
+	})
+
+	t.Run("DeclaredTwice", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Stages("a", "b"), fx.Stages("a"))
+		assert.EqualError(t, app.Err(), `lifecycle stage "a" declared twice`)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "fx.Stages(config, default)",
+			fx.Stages("config", fx.DefaultStage).(interface{ String() string }).String())
+	})
+}
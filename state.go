@@ -0,0 +1,126 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import "fmt"
+
+// State is the stage of its lifecycle an application is in. See App.State.
+type State int
+
+// Applications begin in StateNew. Start moves them through StateStarting to
+// StateStarted, or to StateFailed if any OnStart hook fails. Stop moves
+// started and failed applications through StateStopping to StateStopped.
+//
+// Stopped and failed applications can only be started again if they were
+// built with the Restartable option.
+const (
+	StateNew State = iota
+	StateStarting
+	StateStarted
+	StateStopping
+	StateStopped
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateStarting:
+		return "starting"
+	case StateStarted:
+		return "started"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Restartable is an Option that allows an application to be started again
+// after it has stopped or failed to start. Every start runs all OnStart
+// hooks again, and every stop runs the OnStop hooks of the hooks that
+// started. Values in the container are not rebuilt.
+//
+// This is mostly useful for tests that start and stop the same application
+// several times.
+//
+// 允许app在停止或启动失败后再次启动
+var Restartable Option = restartableOption{}
+
+type restartableOption struct{}
+
+func (restartableOption) apply(app *App) { app.restartable = true }
+
+func (restartableOption) String() string { return "fx.Restartable" }
+
+// State returns the application's current state.
+//
+// 返回app当前所处的状态
+func (app *App) State() State {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	return app.state
+}
+
+// beginStart moves the application to StateStarting, or explains why it
+// can't be started.
+func (app *App) beginStart() error {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+
+	switch app.state {
+	case StateNew:
+	case StateStopped, StateFailed:
+		if !app.restartable {
+			return fmt.Errorf("cannot start application: it is %v; use fx.Restartable to allow restarts", app.state)
+		}
+	default:
+		return fmt.Errorf("cannot start application: it is %v", app.state)
+	}
+	app.state = StateStarting
+	app.drained = false
+	return nil
+}
+
+// beginStop moves the application to StateStopping, or explains why it
+// can't be stopped.
+func (app *App) beginStop() error {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+
+	switch app.state {
+	case StateStarted, StateFailed:
+	default:
+		return fmt.Errorf("cannot stop application: it is %v", app.state)
+	}
+	app.state = StateStopping
+	return nil
+}
+
+func (app *App) setState(s State) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.state = s
+}
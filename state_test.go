@@ -0,0 +1,136 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+)
+
+func TestAppState(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Transitions", func(t *testing.T) {
+		app := fx.New(fx.NopLogger)
+		assert.Equal(t, fx.StateNew, app.State())
+
+		require.NoError(t, app.Start(ctx))
+		assert.Equal(t, fx.StateStarted, app.State())
+		require.NoError(t, app.Stop(ctx))
+		assert.Equal(t, fx.StateStopped, app.State())
+	})
+
+	t.Run("StartingAndStopping", func(t *testing.T) {
+		var app *fx.App
+		var onStart, onStop fx.State
+		app = fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					onStart = app.State()
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					onStop = app.State()
+					return nil
+				},
+			})
+		}))
+		require.NoError(t, app.Start(ctx))
+		require.NoError(t, app.Stop(ctx))
+		assert.Equal(t, fx.StateStarting, onStart)
+		assert.Equal(t, fx.StateStopping, onStop)
+	})
+
+	t.Run("IllegalTransitions", func(t *testing.T) {
+		app := fx.New(fx.NopLogger)
+		assert.EqualError(t, app.Stop(ctx), "cannot stop application: it is new")
+
+		require.NoError(t, app.Start(ctx))
+		assert.EqualError(t, app.Start(ctx), "cannot start application: it is started")
+
+		require.NoError(t, app.Stop(ctx))
+		assert.EqualError(t, app.Stop(ctx), "cannot stop application: it is stopped")
+		assert.EqualError(t, app.Start(ctx),
+			"cannot start application: it is stopped; use fx.Restartable to allow restarts")
+	})
+
+	t.Run("StopAfterFailedStart", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{OnStart: func(context.Context) error {
+				return errors.New("great sadness")
+			}})
+		}))
+		require.Error(t, app.Start(ctx))
+		assert.Equal(t, fx.StateFailed, app.State())
+
+		require.NoError(t, app.Stop(ctx))
+		assert.Equal(t, fx.StateStopped, app.State())
+	})
+
+	t.Run("FailedNew", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Invoke(func(string) {}))
+		require.Error(t, app.Err())
+		require.Error(t, app.Start(ctx))
+		assert.Equal(t, fx.StateFailed, app.State())
+	})
+
+	t.Run("Restartable", func(t *testing.T) {
+		var starts, stops int
+		app := fx.New(fx.NopLogger, fx.Restartable, fx.Invoke(func(lc fx.Lifecycle) {
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					starts++
+					if starts == 2 {
+						return errors.New("great sadness")
+					}
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					stops++
+					return nil
+				},
+			})
+		}))
+
+		require.NoError(t, app.Start(ctx))
+		require.NoError(t, app.Stop(ctx))
+
+		// A failed start can be retried without stopping first.
+		require.Error(t, app.Start(ctx))
+		assert.Equal(t, fx.StateFailed, app.State())
+
+		require.NoError(t, app.Start(ctx))
+		require.NoError(t, app.Stop(ctx))
+		assert.Equal(t, 3, starts)
+		assert.Equal(t, 2, stops)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "stopping", fx.StateStopping.String())
+		assert.Equal(t, "State(42)", fx.State(42).String())
+		assert.Equal(t, "fx.Restartable", fx.Restartable.(interface{ String() string }).String())
+	})
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"fx-master/internal/fxreflect"
+)
+
+var _typeOfOut = reflect.TypeOf(Out{})
+
+// Supply provides instantiated values for dependency injection as if they had
+// been provided using a constructor that simply returns them. The most
+// specific type of each value (as determined by reflection) is used. For
+// example, given:
+//
+//  type (
+//    TypeA struct{}
+//    TypeB struct{}
+//    TypeC struct{}
+//  )
+//
+//  var a, b, c = &TypeA{}, TypeB{}, &TypeC{}
+//
+// The following two forms are equivalent:
+//
+//  fx.Supply(a, b, fx.Annotated{Target: c})
+//
+//  fx.Provide(
+//    func() *TypeA { return a },
+//    func() TypeB { return b },
+//    fx.Annotated{Target: func() *TypeC { return c }},
+//  )
+//
+// Unlike the constructor form, Supply logs the type of each value along with
+// the function that supplied it, rather than an anonymous closure.
+//
+// Values may be wrapped in Annotated to give them a name or add them to a
+// value group, and Private may be passed alongside them to keep them inside
+// the enclosing fx.Module. Supplying an error or a nil value fails the
+// application.
+//
+// Supply直接将已经实例化的值提供给容器，等价于提供一个直接返回该值的构造函数；日志中会输出值的真实类型及调用Supply的位置。
+func Supply(values ...interface{}) Option {
+	o := supplyOption{caller: fxreflect.Caller(), pc: fxreflect.CallerPC()}
+	for i, value := range values {
+		if _, ok := value.(privateOption); ok {
+			o.private = true
+			continue
+		}
+
+		constructor, err := supplyConstructor(value)
+		if err != nil {
+			return Error(fmt.Errorf("fx.Supply received invalid value %d: %v", i+1, err))
+		}
+		o.provides = append(o.provides, constructor)
+		o.values = append(o.values, value)
+	}
+	return o
+}
+
+type supplyOption struct {
+	provides []interface{}
+	values   []interface{}
+	caller   string
+	pc       uintptr
+	private  bool
+}
+
+func (o supplyOption) apply(app *App) {
+	for i, c := range o.provides {
+		app.provides = append(app.provides, provide{
+			target:  c,
+			module:  app.module,
+			private: o.private,
+			supplied: &suppliedValue{
+				typ:    valueTypeName(o.values[i]),
+				caller: o.caller,
+				pc:     o.pc,
+			},
+		})
+	}
+}
+
+func (o supplyOption) String() string {
+	return fmt.Sprintf("fx.Supply(%s)", valueTypeNames(o.values))
+}
+
+// Replace overrides values that were already provided to the application
+// with the given instantiated values. The most specific type of each value
+// (as determined by reflection) is replaced. For example, given:
+//
+//  type Config struct{ ... }
+//
+//  var testConfig = Config{ ... }
+//
+// The following two forms are equivalent:
+//
+//  fx.Replace(testConfig)
+//
+//  fx.Decorate(func(Config) Config { return testConfig })
+//
+// Like Decorate, Replace is scoped to the fx.Module it's declared in. The
+// application fails if the type being replaced wasn't provided, or isn't
+// visible to that module. Values may be wrapped in Annotated to replace a
+// named value, all values of a value group, or both. Value groups may be
+// empty, so replacing one always succeeds.
+//
+// Replace使用给定的值替换容器中已经Provide的同类型值，其作用范围与Decorate一致。
+func Replace(values ...interface{}) Option {
+	o := replaceOption{
+		values: values,
+		caller: fxreflect.Caller(),
+	}
+	for i, value := range values {
+		decorator, err := replaceDecorator(value)
+		if err != nil {
+			return Error(fmt.Errorf("fx.Replace received invalid value %d: %v", i+1, err))
+		}
+		o.decorates = append(o.decorates, decorator)
+	}
+	return o
+}
+
+type replaceOption struct {
+	decorates []interface{}
+	values    []interface{}
+	caller    string
+}
+
+func (o replaceOption) apply(app *App) {
+	for i, d := range o.decorates {
+		app.decorates = append(app.decorates, decorate{
+			target: d,
+			module: app.module,
+			replaced: &suppliedValue{
+				typ:    valueTypeName(o.values[i]),
+				caller: o.caller,
+				keys:   replacedKeys(o.values[i]),
+			},
+		})
+	}
+}
+
+func (o replaceOption) String() string {
+	return fmt.Sprintf("fx.Replace(%s)", valueTypeNames(o.values))
+}
+
+// suppliedValue describes a value passed to Supply or Replace, so that it
+// can be logged by type rather than by the generated function that wraps it.
+type suppliedValue struct {
+	typ    string   // type of the value, including its name if annotated
+	caller string   // function that called Supply or Replace
+	pc     uintptr  // location of the call to Supply, reported by dig errors
+	keys   []depKey // values that must already be provided, for Replace
+}
+
+// supplyConstructor returns a constructor that produces value. Annotated
+// values are returned as Annotated constructors.
+func supplyConstructor(value interface{}) (interface{}, error) {
+	a, annotated := value.(Annotated)
+	if annotated {
+		value = a.Target
+	}
+
+	v, err := suppliedReflectValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := reflect.MakeFunc(
+		reflect.FuncOf(nil /* params */, []reflect.Type{v.Type()}, false /* variadic */),
+		func([]reflect.Value) []reflect.Value { return []reflect.Value{v} },
+	).Interface()
+
+	if annotated {
+		a.Target = fn
+		return a, nil
+	}
+	return fn, nil
+}
+
+// replaceDecorator returns a decorator that ignores the current value of the
+// type of value and returns value instead.
+func replaceDecorator(value interface{}) (interface{}, error) {
+	a, annotated := value.(Annotated)
+	if annotated {
+		value = a.Target
+	}
+
+	v, err := suppliedReflectValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if !annotated || (a.Name == "" && a.Group == "") {
+		return reflect.MakeFunc(
+			reflect.FuncOf([]reflect.Type{v.Type()}, []reflect.Type{v.Type()}, false /* variadic */),
+			func([]reflect.Value) []reflect.Value { return []reflect.Value{v} },
+		).Interface(), nil
+	}
+
+	// Named values and value groups can only be decorated through fx.In and
+	// fx.Out structs carrying the matching tags. Values that are both named
+	// and in a group replace both.
+	inFields := []reflect.StructField{{Name: "In", Type: _typeOfIn, Anonymous: true}}
+	outFields := []reflect.StructField{{Name: "Out", Type: _typeOfOut, Anonymous: true}}
+	var results []reflect.Value
+	add := func(t reflect.Type, tag string, result reflect.Value) {
+		f := reflect.StructField{
+			Name: fmt.Sprintf("Field%d", len(results)),
+			Type: t,
+			Tag:  reflect.StructTag(tag),
+		}
+		inFields, outFields = append(inFields, f), append(outFields, f)
+		results = append(results, result)
+	}
+	if a.Name != "" {
+		add(v.Type(), fmt.Sprintf("name:%q", a.Name), v)
+	}
+	if a.Group != "" {
+		t := reflect.SliceOf(v.Type())
+		add(t, fmt.Sprintf("group:%q", a.Group), reflect.Append(reflect.MakeSlice(t, 0, 1), v))
+	}
+
+	in, out := reflect.StructOf(inFields), reflect.StructOf(outFields)
+	return reflect.MakeFunc(
+		reflect.FuncOf([]reflect.Type{in}, []reflect.Type{out}, false /* variadic */),
+		func([]reflect.Value) []reflect.Value {
+			r := reflect.New(out).Elem()
+			for i, result := range results {
+				r.Field(i + 1).Set(result)
+			}
+			return []reflect.Value{r}
+		},
+	).Interface(), nil
+}
+
+// replacedKeys returns the keys of the values that Replace overrides with
+// value, leaving out value groups.
+func replacedKeys(value interface{}) []depKey {
+	a, ok := value.(Annotated)
+	if !ok {
+		return []depKey{{t: reflect.TypeOf(value)}}
+	}
+	if a.Name == "" && a.Group != "" {
+		return nil
+	}
+	return []depKey{{t: reflect.TypeOf(a.Target), name: a.Name}}
+}
+
+// checkReplaced returns an error if a value passed to Replace wasn't
+// provided to the module that replaces it, since dig would ignore the
+// decorator.
+func (app *App) checkReplaced(d decorate) error {
+	producers := producersByKey(app.nodesOfKind("constructor"))
+	for _, k := range d.replaced.keys {
+		var found bool
+		for _, p := range producers[k] {
+			found = found || p.visibleTo(d.module.path())
+		}
+		if !found {
+			return fmt.Errorf("fx.Replace(%v) has nothing to replace: %v is not provided", d.replaced.typ, k)
+		}
+	}
+	return nil
+}
+
+func suppliedReflectValue(value interface{}) (reflect.Value, error) {
+	switch value.(type) {
+	case nil:
+		return reflect.Value{}, fmt.Errorf("untyped nil")
+	case error:
+		return reflect.Value{}, fmt.Errorf("error value %v", value)
+	}
+	return reflect.ValueOf(value), nil
+}
+
+// valueTypeName formats the type of a value passed to Supply or Replace,
+// along with its name or value group, the way dig does in its errors.
+func valueTypeName(value interface{}) string {
+	if a, ok := value.(Annotated); ok {
+		return depKey{name: a.Name, group: a.Group}.format(fmt.Sprintf("%T", a.Target))
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func valueTypeNames(values []interface{}) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = valueTypeName(v)
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+)
+
+func TestSupply(t *testing.T) {
+	type A struct{ n int }
+	type B struct{ n int }
+
+	t.Run("NothingIsSupplied", func(t *testing.T) {
+		app := fxtest.New(t, fx.Supply())
+		defer app.RequireStart().RequireStop()
+		require.NoError(t, app.Err())
+	})
+
+	t.Run("SomethingIsSupplied", func(t *testing.T) {
+		aIn := &A{n: 1}
+		var aOut *A
+		app := fxtest.New(t,
+			fx.Supply(aIn),
+			fx.Populate(&aOut),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.True(t, aIn == aOut, "expected the supplied pointer")
+	})
+
+	t.Run("SeveralThingsAreSupplied", func(t *testing.T) {
+		var (
+			aOut *A
+			bOut B
+		)
+		app := fxtest.New(t,
+			fx.Supply(&A{n: 1}, B{n: 2}),
+			fx.Populate(&aOut, &bOut),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, 1, aOut.n)
+		assert.Equal(t, 2, bOut.n)
+	})
+
+	t.Run("AnnotatedValuesAreSupplied", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Named *A   `name:"foo"`
+			Group []*A `group:"bar"`
+		}
+
+		var p params
+		app := fxtest.New(t,
+			fx.Supply(
+				fx.Annotated{Name: "foo", Target: &A{n: 1}},
+				fx.Annotated{Group: "bar", Target: &A{n: 2}},
+				fx.Annotated{Group: "bar", Target: &A{n: 3}},
+			),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, 1, p.Named.n)
+		assert.Len(t, p.Group, 2)
+	})
+
+	t.Run("PrivateValuesStayInModule", func(t *testing.T) {
+		app := fx.New(
+			fx.NopLogger,
+			fx.Module("foo", fx.Supply(fx.Private, &A{})),
+			fx.Invoke(func(*A) {}),
+		)
+		require.Error(t, app.Err())
+		assert.Contains(t, app.Err().Error(), `*fx_test.A is private to module "foo"`)
+	})
+
+	t.Run("LogsTypeAndCaller", func(t *testing.T) {
+		spy := moduleLogSpy{&bytes.Buffer{}}
+		app := fx.New(
+			fx.Logger(spy),
+			fx.Module("foo",
+				fx.Supply(&A{}, fx.Annotated{Name: "b", Target: B{}}),
+			),
+		)
+		require.NoError(t, app.Err())
+		assert.Regexp(t, `SUPPLY\t\*fx_test.A <= go.uber.org/fx_test.TestSupply.func\d+ from module "foo"`, spy.String())
+		assert.Regexp(t, `SUPPLY\tfx_test.B\[name="b"\] <= go.uber.org/fx_test.TestSupply.func\d+ from module "foo"`, spy.String())
+		assert.NotContains(t, spy.String(), "makeFuncStub")
+	})
+
+	t.Run("InvalidValues", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Supply(&A{}, nil))
+		require.Error(t, app.Err())
+		assert.Contains(t, app.Err().Error(), "fx.Supply received invalid value 2: untyped nil")
+
+		app = fx.New(fx.NopLogger, fx.Supply(errors.New("great sadness")))
+		require.Error(t, app.Err())
+		assert.Contains(t, app.Err().Error(), "fx.Supply received invalid value 1: error value great sadness")
+	})
+
+	t.Run("DuplicateTypesFail", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Supply(&A{}, &A{}))
+		require.Error(t, app.Err())
+		assert.Contains(t, app.Err().Error(), "already provided")
+	})
+
+	t.Run("ErrorsNameCaller", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Supply(&A{}), fx.Supply(&A{}))
+		require.Error(t, app.Err())
+		assert.Regexp(t,
+			`function "go.uber.org/fx_test".TestSupply.func\d+ \(.+/supply_test.go:\d+\): .* already provided by "go.uber.org/fx_test".TestSupply.func\d+ \(.+/supply_test.go:\d+\)`,
+			app.Err().Error())
+		assert.NotContains(t, app.Err().Error(), "makeFuncStub")
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t,
+			`fx.Supply(*fx_test.A, fx_test.B[name="foo"])`,
+			fmt.Sprint(fx.Supply(&A{}, fx.Annotated{Name: "foo", Target: B{}})),
+		)
+	})
+}
+
+func TestReplace(t *testing.T) {
+	type A struct{ n int }
+
+	t.Run("ReplacesProvidedValue", func(t *testing.T) {
+		var a *A
+		app := fxtest.New(t,
+			fx.Provide(func() *A { return &A{n: 1} }),
+			fx.Replace(&A{n: 2}),
+			fx.Populate(&a),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, 2, a.n)
+	})
+
+	t.Run("ReplacesSuppliedValue", func(t *testing.T) {
+		var a A
+		app := fxtest.New(t,
+			fx.Supply(A{n: 1}),
+			fx.Replace(A{n: 2}),
+			fx.Populate(&a),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, 2, a.n)
+	})
+
+	t.Run("ReplacesNamedValue", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Foo *A `name:"foo"`
+			Bar *A `name:"bar"`
+		}
+
+		var p params
+		app := fxtest.New(t,
+			fx.Supply(
+				fx.Annotated{Name: "foo", Target: &A{n: 1}},
+				fx.Annotated{Name: "bar", Target: &A{n: 1}},
+			),
+			fx.Replace(fx.Annotated{Name: "foo", Target: &A{n: 2}}),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, 2, p.Foo.n)
+		assert.Equal(t, 1, p.Bar.n)
+	})
+
+	t.Run("ReplacesValueGroup", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			As []*A `group:"as"`
+		}
+
+		var p params
+		app := fxtest.New(t,
+			fx.Supply(
+				fx.Annotated{Group: "as", Target: &A{n: 1}},
+				fx.Annotated{Group: "as", Target: &A{n: 1}},
+			),
+			fx.Replace(fx.Annotated{Group: "as", Target: &A{n: 2}}),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+		require.Len(t, p.As, 1)
+		assert.Equal(t, 2, p.As[0].n)
+	})
+
+	t.Run("ReplacesNamedValueGroup", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			Foo *A   `name:"foo"`
+			As  []*A `group:"as"`
+		}
+
+		var p params
+		app := fxtest.New(t,
+			fx.Supply(fx.Annotated{Name: "foo", Group: "as", Target: &A{n: 1}}),
+			fx.Replace(fx.Annotated{Name: "foo", Group: "as", Target: &A{n: 2}}),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, 2, p.Foo.n)
+		require.Len(t, p.As, 1)
+		assert.Same(t, p.Foo, p.As[0])
+	})
+
+	t.Run("ScopedToModule", func(t *testing.T) {
+		var inside, outside *A
+		app := fxtest.New(t,
+			fx.Supply(&A{n: 1}),
+			fx.Module("foo",
+				fx.Replace(&A{n: 2}),
+				fx.Invoke(func(a *A) { inside = a }),
+			),
+			fx.Invoke(func(a *A) { outside = a }),
+		)
+		defer app.RequireStart().RequireStop()
+		assert.Equal(t, 2, inside.n)
+		assert.Equal(t, 1, outside.n)
+	})
+
+	t.Run("LogsTypeAndCaller", func(t *testing.T) {
+		spy := moduleLogSpy{&bytes.Buffer{}}
+		app := fx.New(
+			fx.Logger(spy),
+			fx.Supply(&A{}),
+			fx.Replace(&A{}),
+		)
+		require.NoError(t, app.Err())
+		assert.Regexp(t, `REPLACE\t\*fx_test.A <= go.uber.org/fx_test.TestReplace.func\d+\n`, spy.String())
+	})
+
+	t.Run("MissingValueFails", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			opt  fx.Option
+		}{
+			{desc: "NotProvided", opt: fx.Replace(&A{})},
+			{desc: "NotNamed", opt: fx.Options(fx.Supply(&A{}), fx.Replace(fx.Annotated{Name: "foo", Target: &A{}}))},
+			{desc: "Private", opt: fx.Options(fx.Module("foo", fx.Supply(fx.Private, &A{})), fx.Replace(&A{}))},
+		}
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				app := fx.New(fx.NopLogger, tt.opt)
+				require.Error(t, app.Err())
+				assert.Contains(t, app.Err().Error(), "has nothing to replace")
+			})
+		}
+	})
+
+	t.Run("EmptyValueGroup", func(t *testing.T) {
+		var p struct {
+			fx.In
+
+			As []*A `group:"as"`
+		}
+		app := fxtest.New(t,
+			fx.Replace(fx.Annotated{Group: "as", Target: &A{n: 1}}),
+			fx.Populate(&p),
+		)
+		defer app.RequireStart().RequireStop()
+		require.Len(t, p.As, 1)
+		assert.Equal(t, 1, p.As[0].n)
+	})
+
+	t.Run("InvalidValue", func(t *testing.T) {
+		app := fx.New(fx.NopLogger, fx.Replace(nil))
+		require.Error(t, app.Err())
+		assert.Contains(t, app.Err().Error(), "fx.Replace received invalid value 1: untyped nil")
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, `fx.Replace(*fx_test.A)`, fmt.Sprint(fx.Replace(&A{})))
+	})
+}
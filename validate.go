@@ -0,0 +1,51 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx
+
+// ValidateApp builds the dependency graph of an application without running
+// it, and returns the first error New would have found in it. It checks that
+// every constructor was provided correctly, that the graph has no cycles,
+// and that every function passed to Invoke, Populate or Extract can get all
+// of its parameters.
+//
+// No constructors, decorators or invoked functions are called, so
+// ValidateApp is safe to use in tests for applications whose constructors
+// open connections or start goroutines. Like the errors returned by Err,
+// errors caused by the dependency graph carry a DOT visualization of it
+// (see VisualizeError).
+//
+// 只构建依赖关系图而不调用任何构造函数，用于在CI中检查依赖是否完整
+func ValidateApp(opts ...Option) error {
+	opts = append([]Option{validateOption{}}, opts...)
+	app := New(opts...)
+	if err := app.Err(); err != nil {
+		return app.withGraph(err)
+	}
+	return nil
+}
+
+// validateOption makes New build a dry-run container. New looks for it
+// before applying any other option since the container is built first.
+type validateOption struct{}
+
+func (validateOption) apply(*App) {}
+
+func (validateOption) String() string { return "fx.validate" }
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fx_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+)
+
+func TestValidateApp(t *testing.T) {
+	type A struct{}
+	type B struct{}
+
+	t.Run("DoesntCallFunctions", func(t *testing.T) {
+		var called []string
+		var a *A
+		err := fx.ValidateApp(
+			fx.NopLogger,
+			fx.Provide(func() *A {
+				called = append(called, "constructor")
+				return &A{}
+			}),
+			fx.Decorate(func(a *A) *A {
+				called = append(called, "decorator")
+				return a
+			}),
+			fx.Invoke(func(*A) { called = append(called, "invoke") }),
+			fx.Populate(&a),
+		)
+		require.NoError(t, err)
+		assert.Empty(t, called)
+		assert.Nil(t, a, "Populate shouldn't fill in targets")
+	})
+
+	t.Run("MissingDependency", func(t *testing.T) {
+		err := fx.ValidateApp(
+			fx.NopLogger,
+			fx.Provide(func(*B) *A { return &A{} }),
+			fx.Invoke(func(*A) {}),
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing type: *fx_test.B")
+
+		graph, vizErr := fx.VisualizeError(err)
+		require.NoError(t, vizErr)
+		assert.Contains(t, graph, "digraph")
+	})
+
+	t.Run("MissingNamedValue", func(t *testing.T) {
+		type params struct {
+			fx.In
+
+			A *A `name:"primary"`
+		}
+		err := fx.ValidateApp(
+			fx.NopLogger,
+			fx.Provide(fx.Annotated{Name: "secondary", Target: func() *A { return &A{} }}),
+			fx.Invoke(func(params) {}),
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `*fx_test.A[name="primary"]`)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		err := fx.ValidateApp(
+			fx.NopLogger,
+			fx.Provide(
+				func(*B) *A { return &A{} },
+				func(*A) *B { return &B{} },
+			),
+		)
+		require.Error(t, err, "cycles should be found without any invokes")
+		assert.Contains(t, err.Error(), "cycle")
+	})
+
+	t.Run("PrivateValue", func(t *testing.T) {
+		err := fx.ValidateApp(
+			fx.NopLogger,
+			fx.Module("child",
+				fx.Provide(fx.Private, func() *A { return &A{} }),
+			),
+			fx.Invoke(func(*A) {}),
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `is private to module "child"`)
+	})
+
+	t.Run("WithLogger", func(t *testing.T) {
+		err := fx.ValidateApp(
+			fx.NopLogger,
+			fx.WithLogger(func(*B) fxevent.Logger { return fxevent.NopLogger }),
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing type: *fx_test.B")
+	})
+}